@@ -0,0 +1,73 @@
+package api_auth
+
+import (
+	"codegenhw/api_error"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+)
+
+// Principal is the caller a request is authenticated as.
+type Principal struct {
+	// ID identifies the caller, e.g. a login or a key id
+	ID string
+	// Roles are what the caller is allowed to do
+	Roles []string
+}
+
+// HasAnyRole tells whether p has at least one of roles, a nil principal has
+// none.
+func (p *Principal) HasAnyRole(roles ...string) bool {
+	if p == nil {
+		return false
+	}
+	for _, role := range roles {
+		for _, has := range p.Roles {
+			if has == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Authenticator checks the credentials of requests to endpoints with
+// "auth": true. It returns a non-nil principal or an error, an
+// api_error.ApiError sets the status of the response and any other error
+// is answered with 500. A nil principal without an error is answered with 401.
+//
+// Generated handlers use the field of this type of the service, or Default
+// when there is no such field or it is nil.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthenticatorFunc lets an ordinary function be an Authenticator.
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// HeaderToken accepts requests whose Header is equal to Token, the principal
+// has no id and no roles. Other requests get 403. Without Token every request
+// fails with 500, a missing header must not match it.
+type HeaderToken struct {
+	Header string
+	Token  string
+}
+
+func (a HeaderToken) Authenticate(r *http.Request) (*Principal, error) {
+	if a.Token == "" {
+		return nil, api_error.ApiError{HTTPStatus: http.StatusInternalServerError, Err: errors.New("header token authenticator has no token")}
+	}
+	values, ok := r.Header[http.CanonicalHeaderKey(a.Header)]
+	if !ok || subtle.ConstantTimeCompare([]byte(values[0]), []byte(a.Token)) != 1 {
+		return nil, api_error.ApiError{HTTPStatus: http.StatusForbidden, Err: errors.New("unauthorized")}
+	}
+	return &Principal{}, nil
+}
+
+// Default is the authenticator of services without one of their own, it
+// expects X-Auth: 100500.
+var Default Authenticator = HeaderToken{Header: "X-Auth", Token: "100500"}
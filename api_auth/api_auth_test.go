@@ -0,0 +1,65 @@
+package api_auth
+
+import (
+	"codegenhw/api_error"
+	"net/http"
+	"testing"
+)
+
+func TestHeaderToken(t *testing.T) {
+	cases := []struct {
+		// Auth defaults to Default
+		Auth   Authenticator
+		Header http.Header
+		Status int
+	}{
+		{
+			Header: http.Header{"X-Auth": {"100500"}},
+			Status: 0,
+		},
+		{
+			Header: http.Header{"X-Auth": {"100501"}},
+			Status: http.StatusForbidden,
+		},
+		{
+			Header: http.Header{},
+			Status: http.StatusForbidden,
+		},
+		{
+			Auth:   HeaderToken{Header: "X-Auth"},
+			Header: http.Header{"X-Auth": {""}},
+			Status: http.StatusInternalServerError,
+		},
+	}
+
+	for i, item := range cases {
+		auth := item.Auth
+		if auth == nil {
+			auth = Default
+		}
+		r := &http.Request{Header: item.Header}
+		principal, err := auth.Authenticate(r)
+		if item.Status == 0 {
+			if err != nil || principal == nil {
+				t.Errorf("[%d] request is not authenticated: %v", i, err)
+			}
+			continue
+		}
+		if apiError, ok := err.(api_error.ApiError); !ok || apiError.HTTPStatus != item.Status {
+			t.Errorf("[%d] expected status %v, got %v", i, item.Status, err)
+		}
+	}
+}
+
+func TestHasAnyRole(t *testing.T) {
+	principal := &Principal{ID: "rvasily", Roles: []string{"user", "moderator"}}
+	if !principal.HasAnyRole("admin", "moderator") {
+		t.Errorf("moderator is not found")
+	}
+	if principal.HasAnyRole("admin") || principal.HasAnyRole() {
+		t.Errorf("missing role is found")
+	}
+	if (*Principal)(nil).HasAnyRole("user") {
+		t.Errorf("nil principal has a role")
+	}
+}
@@ -0,0 +1,205 @@
+package api_auth
+
+import (
+	"bytes"
+	"codegenhw/api_error"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// JWT accepts requests with an "Authorization: Bearer <token>" header holding
+// a JSON Web Token signed with HMAC-SHA256 (alg HS256) and Key. The principal
+// id is the "sub" claim and its roles come from RolesClaim, which is either a
+// list of strings or a space separated string like "scope".
+//
+// A missing, malformed, badly signed, expired or not yet valid token is
+// answered with 401, a valid token issued for another audience with 403.
+// Without Key every request fails with 500.
+type JWT struct {
+	Key []byte
+	// Audience, when set, has to be one of the "aud" values of the token
+	Audience string
+	// RolesClaim defaults to "roles"
+	RolesClaim string
+	// Leeway is the clock skew tolerated in "exp" and "nbf" checks
+	Leeway time.Duration
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// jwtHeader is the HS256 JOSE header, the only one accepted and issued.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// errNoJWTKey fails requests to a JWT authenticator without a key instead of
+// accepting tokens anyone can sign.
+var errNoJWTKey = api_error.ApiError{HTTPStatus: http.StatusInternalServerError, Err: errors.New("jwt authenticator has no key")}
+
+func (a JWT) Authenticate(r *http.Request) (*Principal, error) {
+	if len(a.Key) == 0 {
+		return nil, errNoJWTKey
+	}
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, unauthorized("bearer token required")
+	}
+	claims, err := a.verify(strings.TrimSpace(token))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	if exp, ok, err := numericDate(claims, "exp"); err != nil {
+		return nil, err
+	} else if ok && !now().Before(exp.Add(a.Leeway)) {
+		return nil, unauthorized("token is expired")
+	}
+	if nbf, ok, err := numericDate(claims, "nbf"); err != nil {
+		return nil, err
+	} else if ok && now().Add(a.Leeway).Before(nbf) {
+		return nil, unauthorized("token is not valid yet")
+	}
+	if a.Audience != "" {
+		audience, err := stringList(claims, "aud")
+		if err != nil {
+			return nil, err
+		}
+		if !contains(audience, a.Audience) {
+			return nil, api_error.ApiError{HTTPStatus: http.StatusForbidden, Err: errors.New("token is issued for another audience")}
+		}
+	}
+
+	principal := &Principal{}
+	if sub, ok := claims["sub"]; ok {
+		if err := json.Unmarshal(sub, &principal.ID); err != nil {
+			return nil, unauthorized("invalid token: sub must be a string")
+		}
+	}
+	rolesClaim := a.RolesClaim
+	if rolesClaim == "" {
+		rolesClaim = "roles"
+	}
+	if principal.Roles, err = stringList(claims, rolesClaim); err != nil {
+		return nil, err
+	}
+	return principal, nil
+}
+
+// Issue signs claims with Key, it is meant for tests and for services issuing
+// their own tokens. Times in claims are expected as unix seconds.
+func (a JWT) Issue(claims map[string]any) (string, error) {
+	if len(a.Key) == 0 {
+		return "", errNoJWTKey
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(a.sign(signed)), nil
+}
+
+// verify checks the header and the signature of token and returns its raw
+// claims.
+func (a JWT) verify(token string) (map[string]json.RawMessage, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, unauthorized("invalid token")
+	}
+	headerData, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, unauthorized("invalid token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerData, &header); err != nil {
+		return nil, unauthorized("invalid token")
+	}
+	// the algorithm is fixed, "none" or asymmetric ones signed with Key
+	// as a public key must not pass
+	if header.Alg != "HS256" {
+		return nil, unauthorized("unsupported token algorithm " + header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, a.sign(parts[0]+"."+parts[1])) {
+		return nil, unauthorized("invalid token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, unauthorized("invalid token")
+	}
+	var claims map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &claims); err != nil || claims == nil {
+		return nil, unauthorized("invalid token claims")
+	}
+	return claims, nil
+}
+
+func (a JWT) sign(data string) []byte {
+	mac := hmac.New(sha256.New, a.Key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// numericDate reads claim name in unix seconds, fractions allowed.
+func numericDate(claims map[string]json.RawMessage, name string) (time.Time, bool, error) {
+	raw, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	var seconds float64
+	if err := json.Unmarshal(raw, &seconds); err != nil {
+		return time.Time{}, false, unauthorized("invalid token: " + name + " must be a number")
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)), true, nil
+}
+
+// stringList reads claim name given either as a list of strings or as one
+// string, which is split by spaces, except for "aud" where it is a single
+// value.
+func stringList(claims map[string]json.RawMessage, name string) ([]string, error) {
+	raw, ok := claims[name]
+	if !ok {
+		return nil, nil
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		var list []string
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, unauthorized("invalid token: " + name + " must be a list of strings")
+		}
+		return list, nil
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, unauthorized("invalid token: " + name + " must be a string or a list of strings")
+	}
+	if name == "aud" {
+		return []string{value}, nil
+	}
+	return strings.Fields(value), nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func unauthorized(message string) error {
+	return api_error.ApiError{HTTPStatus: http.StatusUnauthorized, Err: errors.New(message)}
+}
@@ -0,0 +1,162 @@
+package api_auth
+
+import (
+	"codegenhw/api_error"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJWT(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	auth := JWT{
+		Key:      []byte("secret"),
+		Audience: "api",
+		Leeway:   time.Minute,
+		Now:      func() time.Time { return now },
+	}
+	issue := func(key string, claims map[string]any) string {
+		token, err := JWT{Key: []byte(key)}.Issue(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + token
+	}
+	valid := map[string]any{"sub": "rvasily", "aud": "api", "exp": now.Unix() + 60, "roles": []string{"admin"}}
+	with := func(name string, value any) map[string]any {
+		claims := map[string]any{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	noneHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	validToken := issue("secret", valid)
+
+	cases := []struct {
+		Authorization string
+		Principal     *Principal
+		Status        int
+	}{
+		{
+			Authorization: validToken,
+			Principal:     &Principal{ID: "rvasily", Roles: []string{"admin"}},
+		},
+		{
+			Authorization: issue("secret", with("roles", "user moderator")),
+			Principal:     &Principal{ID: "rvasily", Roles: []string{"user", "moderator"}},
+		},
+		{
+			Authorization: issue("secret", with("aud", []string{"web", "api"})),
+			Principal:     &Principal{ID: "rvasily", Roles: []string{"admin"}},
+		},
+		{
+			// expired within the leeway
+			Authorization: issue("secret", with("exp", now.Unix()-30)),
+			Principal:     &Principal{ID: "rvasily", Roles: []string{"admin"}},
+		},
+		{
+			Authorization: issue("secret", with("exp", now.Unix()-120)),
+			Status:        http.StatusUnauthorized,
+		},
+		{
+			Authorization: issue("secret", with("nbf", now.Unix()+120)),
+			Status:        http.StatusUnauthorized,
+		},
+		{
+			Authorization: issue("secret", with("exp", "tomorrow")),
+			Status:        http.StatusUnauthorized,
+		},
+		{
+			Authorization: issue("other", valid),
+			Status:        http.StatusUnauthorized,
+		},
+		{
+			Authorization: "Bearer " + noneHeader + strings.TrimPrefix(validToken, "Bearer "+jwtHeader),
+			Status:        http.StatusUnauthorized,
+		},
+		{
+			Authorization: "Bearer not.a.token",
+			Status:        http.StatusUnauthorized,
+		},
+		{
+			Authorization: strings.Replace(validToken, "Bearer", "Basic", 1),
+			Status:        http.StatusUnauthorized,
+		},
+		{
+			Authorization: "",
+			Status:        http.StatusUnauthorized,
+		},
+		{
+			Authorization: issue("secret", with("aud", "web")),
+			Status:        http.StatusForbidden,
+		},
+		{
+			Authorization: issue("secret", with("aud", nil)),
+			Status:        http.StatusForbidden,
+		},
+	}
+
+	for i, item := range cases {
+		r := &http.Request{Header: http.Header{}}
+		if item.Authorization != "" {
+			r.Header.Set("Authorization", item.Authorization)
+		}
+		principal, err := auth.Authenticate(r)
+		if item.Status == 0 {
+			if err != nil {
+				t.Errorf("[%d] request is not authenticated: %v", i, err)
+			} else if !reflect.DeepEqual(principal, item.Principal) {
+				t.Errorf("[%d] expected principal %+v, got %+v", i, item.Principal, principal)
+			}
+			continue
+		}
+		if apiError, ok := err.(api_error.ApiError); !ok || apiError.HTTPStatus != item.Status {
+			t.Errorf("[%d] expected status %v, got %v", i, item.Status, err)
+		}
+	}
+}
+
+func TestJWTNoKey(t *testing.T) {
+	// a token signed with an empty key must not pass an authenticator
+	// someone forgot to configure
+	mac := hmac.New(sha256.New, nil)
+	signed := jwtHeader + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","roles":["admin"]}`))
+	mac.Write([]byte(signed))
+	token := signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	r := &http.Request{Header: http.Header{"Authorization": {"Bearer " + token}}}
+	principal, err := JWT{}.Authenticate(r)
+	if apiError, ok := err.(api_error.ApiError); !ok || apiError.HTTPStatus != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %+v, %v", principal, err)
+	}
+	if _, err := (JWT{}).Issue(map[string]any{"sub": "admin"}); err == nil {
+		t.Errorf("token is issued without a key")
+	}
+}
+
+func TestJWTRolesClaim(t *testing.T) {
+	auth := JWT{Key: []byte("secret"), RolesClaim: "scope"}
+	token, err := auth.Issue(map[string]any{"sub": "rvasily", "scope": "read write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &http.Request{Header: http.Header{"Authorization": {"Bearer " + token}}}
+	principal, err := auth.Authenticate(r)
+	if err != nil {
+		t.Fatalf("request is not authenticated: %v", err)
+	}
+	if !principal.HasAnyRole("write") {
+		t.Errorf("expected scope roles, got %v", principal.Roles)
+	}
+}
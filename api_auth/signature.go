@@ -0,0 +1,167 @@
+package api_auth
+
+import (
+	"bytes"
+	"codegenhw/api_error"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers of signed requests.
+const (
+	SignatureKeyHeader       = "X-Signature-Key"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureHeader          = "X-Signature"
+)
+
+// DefaultSignatureWindow is how far the timestamp of a signed request may be
+// from the server time when HMACSignature.Window is not set.
+const DefaultSignatureWindow = 5 * time.Minute
+
+// DefaultMaxSignedBody is the largest body HMACSignature reads to check the
+// signature when MaxBodySize is not set, the same as http.Request.ParseForm
+// reads.
+const DefaultMaxSignedBody = 10 << 20
+
+// HMACSignature accepts requests signed by a Signer with one of Keys. The key
+// id is sent in X-Signature-Key, the unix time of signing in
+// X-Signature-Timestamp and the hex encoded HMAC-SHA256 of the method, the path
+// with the query, the timestamp and the SHA-256 of the body in X-Signature.
+//
+// The principal id is the key id and its roles are Roles of the key. Requests
+// with missing or wrong signatures, unknown keys or timestamps outside of
+// Window get 401, so a captured request can not be replayed later. Bodies
+// larger than MaxBodySize get 413 and keys with an empty secret fail requests
+// with 500.
+type HMACSignature struct {
+	// Keys are shared secrets by key id
+	Keys map[string][]byte
+	// Roles are the roles of callers by key id
+	Roles map[string][]string
+	// Window defaults to DefaultSignatureWindow
+	Window time.Duration
+	// MaxBodySize defaults to DefaultMaxSignedBody
+	MaxBodySize int64
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+func (a HMACSignature) Authenticate(r *http.Request) (*Principal, error) {
+	keyID := r.Header.Get(SignatureKeyHeader)
+	timestamp := r.Header.Get(SignatureTimestampHeader)
+	signature, err := hex.DecodeString(r.Header.Get(SignatureHeader))
+	if keyID == "" || timestamp == "" || err != nil || len(signature) == 0 {
+		return nil, unauthorized("request signature required")
+	}
+	key, ok := a.Keys[keyID]
+	if !ok {
+		return nil, unauthorized("unknown signature key " + keyID)
+	}
+	if len(key) == 0 {
+		return nil, api_error.ApiError{HTTPStatus: http.StatusInternalServerError, Err: errors.New("signature key " + keyID + " has no secret")}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, unauthorized("invalid signature timestamp")
+	}
+	now, window := time.Now, a.Window
+	if a.Now != nil {
+		now = a.Now
+	}
+	if window == 0 {
+		window = DefaultSignatureWindow
+	}
+	if skew := now().Sub(time.Unix(seconds, 0)); skew > window || skew < -window {
+		return nil, unauthorized("request signature is expired")
+	}
+
+	maxBodySize := a.MaxBodySize
+	if maxBodySize == 0 {
+		maxBodySize = DefaultMaxSignedBody
+	}
+	body, err := readBody(r, maxBodySize)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, api_error.ApiError{HTTPStatus: http.StatusRequestEntityTooLarge, Err: errors.New("request body is too large")}
+	} else if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(signature, signRequest(key, r, timestamp, body)) {
+		return nil, unauthorized("invalid request signature")
+	}
+	return &Principal{ID: keyID, Roles: a.Roles[keyID]}, nil
+}
+
+// Signer signs requests for HMACSignature. Its Sign method fits the Sign field
+// of generated clients.
+type Signer struct {
+	KeyID string
+	Key   []byte
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// Sign sets the signature headers of r. The body is read and replaced, so r
+// is still good to be sent.
+func (s Signer) Sign(r *http.Request) error {
+	if len(s.Key) == 0 {
+		return errors.New("signer " + s.KeyID + " has no key")
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	body, err := readBody(r, -1)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+	r.Header.Set(SignatureKeyHeader, s.KeyID)
+	r.Header.Set(SignatureTimestampHeader, timestamp)
+	r.Header.Set(SignatureHeader, hex.EncodeToString(signRequest(s.Key, r, timestamp, body)))
+	return nil
+}
+
+// signRequest returns the HMAC-SHA256 of the request lines both sides agree
+// on.
+func signRequest(key []byte, r *http.Request, timestamp string, body []byte) []byte {
+	// servers see the target as it was sent, before any prefix stripping
+	target := r.RequestURI
+	if target == "" {
+		target = r.URL.RequestURI()
+	}
+	bodyHash := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, key)
+	io.WriteString(mac, r.Method+"\n"+target+"\n"+timestamp+"\n"+hex.EncodeToString(bodyHash[:]))
+	return mac.Sum(nil)
+}
+
+// readBody reads the body of r and puts an unread copy back for whoever
+// handles the request next. Bodies over limit bytes fail with
+// *http.MaxBytesError, a negative limit means no limit.
+func readBody(r *http.Request, limit int64) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	if limit >= 0 {
+		if r.ContentLength > limit {
+			return nil, &http.MaxBytesError{Limit: limit}
+		}
+		r.Body = http.MaxBytesReader(nil, r.Body, limit)
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,178 @@
+package api_auth
+
+import (
+	"codegenhw/api_error"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	auth := HMACSignature{
+		Keys:        map[string][]byte{"billing": []byte("secret"), "empty": nil},
+		Roles:       map[string][]string{"billing": {"service"}},
+		MaxBodySize: 64,
+		Now:         func() time.Time { return now },
+	}
+	signer := Signer{KeyID: "billing", Key: []byte("secret"), Now: func() time.Time { return now }}
+	signed := func(signer Signer, method, target, body string) *http.Request {
+		r, err := http.NewRequest(method, "http://example.com"+target, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := signer.Sign(r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	at := func(offset time.Duration) Signer {
+		other := signer
+		other.Now = func() time.Time { return now.Add(offset) }
+		return other
+	}
+	tampered := func(r *http.Request, change func(r *http.Request)) *http.Request {
+		change(r)
+		return r
+	}
+
+	cases := []struct {
+		Request *http.Request
+		Status  int
+	}{
+		{
+			Request: signed(signer, "POST", "/user/create?x=1", "login=rvasily"),
+		},
+		{
+			Request: signed(signer, "GET", "/user/profile?login=rvasily", ""),
+		},
+		{
+			Request: signed(at(-4*time.Minute), "GET", "/user/profile", ""),
+		},
+		{
+			Request: signed(at(-6*time.Minute), "GET", "/user/profile", ""),
+			Status:  http.StatusUnauthorized,
+		},
+		{
+			Request: signed(at(6*time.Minute), "GET", "/user/profile", ""),
+			Status:  http.StatusUnauthorized,
+		},
+		{
+			Request: signed(Signer{KeyID: "billing", Key: []byte("other")}, "GET", "/user/profile", ""),
+			Status:  http.StatusUnauthorized,
+		},
+		{
+			Request: signed(Signer{KeyID: "unknown", Key: []byte("secret")}, "GET", "/user/profile", ""),
+			Status:  http.StatusUnauthorized,
+		},
+		{
+			Request: signed(Signer{KeyID: "empty", Key: []byte("secret")}, "GET", "/user/profile", ""),
+			Status:  http.StatusInternalServerError,
+		},
+		{
+			Request: signed(signer, "POST", "/user/create", strings.Repeat("a", 65)),
+			Status:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			// the length is not known in advance
+			Request: tampered(signed(signer, "POST", "/user/create", strings.Repeat("a", 65)), func(r *http.Request) {
+				r.ContentLength = -1
+			}),
+			Status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			Request: tampered(signed(signer, "POST", "/user/create", "login=rvasily"), func(r *http.Request) {
+				r.Body = io.NopCloser(strings.NewReader("login=admin"))
+			}),
+			Status: http.StatusUnauthorized,
+		},
+		{
+			Request: tampered(signed(signer, "GET", "/user/profile?login=rvasily", ""), func(r *http.Request) {
+				r.URL.RawQuery = "login=admin"
+			}),
+			Status: http.StatusUnauthorized,
+		},
+		{
+			Request: tampered(signed(signer, "GET", "/user/profile", ""), func(r *http.Request) {
+				r.Method = "DELETE"
+			}),
+			Status: http.StatusUnauthorized,
+		},
+		{
+			Request: tampered(signed(signer, "GET", "/user/profile", ""), func(r *http.Request) {
+				r.Header.Set(SignatureTimestampHeader, "soon")
+			}),
+			Status: http.StatusUnauthorized,
+		},
+		{
+			Request: tampered(signed(signer, "GET", "/user/profile", ""), func(r *http.Request) {
+				r.Header.Del(SignatureHeader)
+			}),
+			Status: http.StatusUnauthorized,
+		},
+	}
+
+	for i, item := range cases {
+		principal, err := auth.Authenticate(item.Request)
+		if item.Status == 0 {
+			expected := &Principal{ID: "billing", Roles: []string{"service"}}
+			if err != nil {
+				t.Errorf("[%d] request is not authenticated: %v", i, err)
+			} else if !reflect.DeepEqual(principal, expected) {
+				t.Errorf("[%d] expected principal %+v, got %+v", i, expected, principal)
+			}
+			continue
+		}
+		if apiError, ok := err.(api_error.ApiError); !ok || apiError.HTTPStatus != item.Status {
+			t.Errorf("[%d] expected status %v, got %v", i, item.Status, err)
+		}
+	}
+}
+
+func TestSignerNoKey(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://example.com/user/profile", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (Signer{KeyID: "billing"}).Sign(r); err == nil {
+		t.Errorf("request is signed without a key")
+	}
+}
+
+// TestHMACSignatureServer checks a signed request over the wire: the handler
+// behind a stripped prefix still authenticates it and reads the whole body.
+func TestHMACSignatureServer(t *testing.T) {
+	auth := HMACSignature{Keys: map[string][]byte{"billing": []byte("secret")}}
+	handler := http.StripPrefix("/api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := auth.Authenticate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		io.WriteString(w, principal.ID+" "+string(body))
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	r, err := http.NewRequest("POST", server.URL+"/api/user/create?x=1", strings.NewReader("login=rvasily"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (Signer{KeyID: "billing", Key: []byte("secret")}).Sign(r); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "billing login=rvasily" {
+		t.Errorf("unexpected response %v: %s", resp.Status, body)
+	}
+}
@@ -0,0 +1,36 @@
+package api_response
+
+import "net/http"
+
+// Meta is response metadata an annotated method may return between its
+// result and error, e.g. func(ctx, Params) (*User, *Meta, error). The
+// generated handler applies it before writing the json envelope, a nil Meta
+// and metadata returned with an error are ignored.
+type Meta struct {
+	// Status replaces the success status of the endpoint when it is not zero
+	Status int
+	// Header values replace the ones with the same keys, including the
+	// static headers of the annotation
+	Header http.Header
+	// Cookies are added with Set-Cookie headers
+	Cookies []*http.Cookie
+}
+
+// WriteHeader sets the headers and cookies of m on w and writes m.Status, or
+// status when m is nil or has no status of its own.
+func (m *Meta) WriteHeader(w http.ResponseWriter, status int) {
+	if m == nil {
+		w.WriteHeader(status)
+		return
+	}
+	for key, values := range m.Header {
+		w.Header()[http.CanonicalHeaderKey(key)] = values
+	}
+	for _, cookie := range m.Cookies {
+		http.SetCookie(w, cookie)
+	}
+	if m.Status != 0 {
+		status = m.Status
+	}
+	w.WriteHeader(status)
+}
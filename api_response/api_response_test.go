@@ -0,0 +1,48 @@
+package api_response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWriteHeader(t *testing.T) {
+	cases := []struct {
+		Meta   *Meta
+		Status int
+		Header http.Header
+	}{
+		{
+			Meta:   nil,
+			Status: http.StatusCreated,
+			Header: http.Header{"Cache-Control": {"no-store"}},
+		},
+		{
+			Meta:   &Meta{Header: http.Header{"location": {"/user/1"}}},
+			Status: http.StatusCreated,
+			Header: http.Header{"Cache-Control": {"no-store"}, "Location": {"/user/1"}},
+		},
+		{ // заголовки метода заменяют статические
+			Meta: &Meta{
+				Status:  http.StatusAccepted,
+				Header:  http.Header{"Cache-Control": {"max-age=60"}},
+				Cookies: []*http.Cookie{{Name: "session", Value: "1"}},
+			},
+			Status: http.StatusAccepted,
+			Header: http.Header{"Cache-Control": {"max-age=60"}, "Set-Cookie": {"session=1"}},
+		},
+	}
+
+	for i, item := range cases {
+		w := httptest.NewRecorder()
+		w.Header().Set("Cache-Control", "no-store")
+		item.Meta.WriteHeader(w, http.StatusCreated)
+		if w.Code != item.Status {
+			t.Errorf("[%d] wrong status, expected %v, got %v", i, item.Status, w.Code)
+		}
+		if !reflect.DeepEqual(w.Header(), item.Header) {
+			t.Errorf("[%d] wrong header, expected %v, got %v", i, item.Header, w.Header())
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Package api_tag parses apivalidator struct tags, it is shared by
+// ApiValidator and the code generator.
+//
+//	tag   = [ rule { "," rule } ]
+//	rule  = name [ "=" value ]
+//	name  = letter { letter | digit | "_" }
+//	value = { char | "'" { char | "," | "|" } "'" }
+//
+// Spaces around names and unquoted values are dropped. A backslash escapes the
+// next character both inside and outside of quotes: default='a,b',
+// enum=a|'b|c', default=it\'s.
+package api_tag
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Known lists supported tags, the value tells whether the tag takes an argument.
+var Known = map[string]bool{
+	"required":  false,
+	"paramname": true,
+	"enum":      true,
+	"default":   true,
+	"min":       true,
+	"max":       true,
+	"source":    true,
+}
+
+type Rule struct {
+	Name  string
+	Value string
+	// Values is Value split on unquoted "|", used by enum
+	Values   []string
+	HasValue bool
+	// Offsets of the name and the value inside the tag, for diagnostics
+	Offset      int
+	ValueOffset int
+}
+
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("apivalidator tag: %v at offset %d", e.Msg, e.Offset)
+}
+
+type parser struct {
+	tag string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.tag) && p.tag[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func isNameChar(c byte, first bool) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	}
+	return false
+}
+
+func (p *parser) name() (string, error) {
+	start := p.pos
+	for p.pos < len(p.tag) && isNameChar(p.tag[p.pos], p.pos == start) {
+		p.pos++
+	}
+	if p.pos == start {
+		if p.pos == len(p.tag) || p.tag[p.pos] == ',' {
+			return "", p.errorf("empty rule")
+		}
+		return "", p.errorf("unexpected %q in rule name", p.tag[p.pos])
+	}
+	return p.tag[start:p.pos], nil
+}
+
+// value reads up to the next unquoted comma. Unquoted parts are trimmed,
+// quoted ones are kept as is.
+func (p *parser) value() (string, []string, error) {
+	var (
+		value, current strings.Builder
+		values         []string
+		quoted         bool
+		// spaces holds unquoted spaces which are dropped if nothing follows them
+		spaces int
+	)
+	write := func(c byte) {
+		for ; spaces > 0; spaces-- {
+			value.WriteByte(' ')
+			current.WriteByte(' ')
+		}
+		value.WriteByte(c)
+		current.WriteByte(c)
+	}
+
+	p.skipSpaces()
+	for ; p.pos < len(p.tag); p.pos++ {
+		c := p.tag[p.pos]
+		switch {
+		case c == '\\':
+			if p.pos+1 == len(p.tag) {
+				return "", nil, p.errorf("unfinished escape")
+			}
+			p.pos++
+			write(p.tag[p.pos])
+		case c == '\'':
+			if !quoted {
+				for ; spaces > 0; spaces-- {
+					value.WriteByte(' ')
+					current.WriteByte(' ')
+				}
+			}
+			quoted = !quoted
+		case quoted:
+			value.WriteByte(c)
+			current.WriteByte(c)
+		case c == ',':
+			return value.String(), append(values, current.String()), nil
+		case c == ' ':
+			spaces++
+		case c == '|':
+			spaces = 0
+			value.WriteByte(c)
+			values = append(values, current.String())
+			current.Reset()
+			p.pos++
+			p.skipSpaces()
+			p.pos--
+		default:
+			write(c)
+		}
+	}
+	if quoted {
+		return "", nil, p.errorf("unterminated quote")
+	}
+	return value.String(), append(values, current.String()), nil
+}
+
+// Parse splits an apivalidator tag into rules in the order they are written.
+// It checks only the syntax, names and values are up to the caller.
+func Parse(tag string) ([]Rule, error) {
+	var rules []Rule
+	p := &parser{tag: tag}
+	p.skipSpaces()
+	if p.pos == len(tag) {
+		return nil, nil
+	}
+
+	for {
+		p.skipSpaces()
+		rule := Rule{Offset: p.pos}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		rule.Name = name
+
+		p.skipSpaces()
+		if p.pos < len(tag) && tag[p.pos] == '=' {
+			p.pos++
+			rule.HasValue = true
+			rule.ValueOffset = p.pos
+			rule.Value, rule.Values, err = p.value()
+			if err != nil {
+				return nil, err
+			}
+		}
+		rules = append(rules, rule)
+
+		if p.pos == len(tag) {
+			return rules, nil
+		}
+		if tag[p.pos] != ',' {
+			return nil, p.errorf("unexpected %q after rule %v", tag[p.pos], name)
+		}
+		p.pos++
+	}
+}
+
+// Lookup returns rules keyed by name, the last one wins for duplicates.
+func Lookup(rules []Rule) map[string]Rule {
+	res := make(map[string]Rule, len(rules))
+	for _, rule := range rules {
+		res[rule.Name] = rule
+	}
+	return res
+}
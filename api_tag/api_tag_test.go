@@ -0,0 +1,115 @@
+package api_tag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		Tag    string
+		Result []Rule
+	}{
+		{
+			Tag:    "",
+			Result: nil,
+		},
+		{
+			Tag: "required,min=10",
+			Result: []Rule{
+				{Name: "required", Offset: 0},
+				{Name: "min", Value: "10", Values: []string{"10"}, HasValue: true, Offset: 9, ValueOffset: 13},
+			},
+		},
+		{ // имя параметра содержит "min", но правила min нет
+			Tag: "paramname=admin_id",
+			Result: []Rule{
+				{Name: "paramname", Value: "admin_id", Values: []string{"admin_id"}, HasValue: true, ValueOffset: 10},
+			},
+		},
+		{ // значение по умолчанию содержит "max"
+			Tag: "default=maximum",
+			Result: []Rule{
+				{Name: "default", Value: "maximum", Values: []string{"maximum"}, HasValue: true, ValueOffset: 8},
+			},
+		},
+		{
+			Tag: "enum=user|moderator|admin,default=user",
+			Result: []Rule{
+				{Name: "enum", Value: "user|moderator|admin", Values: []string{"user", "moderator", "admin"}, HasValue: true, ValueOffset: 5},
+				{Name: "default", Value: "user", Values: []string{"user"}, HasValue: true, Offset: 26, ValueOffset: 34},
+			},
+		},
+		{
+			Tag: "default='a,b'",
+			Result: []Rule{
+				{Name: "default", Value: "a,b", Values: []string{"a,b"}, HasValue: true, ValueOffset: 8},
+			},
+		},
+		{
+			Tag: "enum='x,y'|z|'p|q', default=a=b",
+			Result: []Rule{
+				{Name: "enum", Value: "x,y|z|p|q", Values: []string{"x,y", "z", "p|q"}, HasValue: true, ValueOffset: 5},
+				{Name: "default", Value: "a=b", Values: []string{"a=b"}, HasValue: true, Offset: 20, ValueOffset: 28},
+			},
+		},
+		{
+			Tag: `default=it\'s\,ok`,
+			Result: []Rule{
+				{Name: "default", Value: "it's,ok", Values: []string{"it's,ok"}, HasValue: true, ValueOffset: 8},
+			},
+		},
+		{
+			Tag: " min = 1 , enum = a | b c ,default=' x '",
+			Result: []Rule{
+				{Name: "min", Value: "1", Values: []string{"1"}, HasValue: true, Offset: 1, ValueOffset: 6},
+				{Name: "enum", Value: "a|b c", Values: []string{"a", "b c"}, HasValue: true, Offset: 11, ValueOffset: 17},
+				{Name: "default", Value: " x ", Values: []string{" x "}, HasValue: true, Offset: 27, ValueOffset: 35},
+			},
+		},
+		{
+			Tag: "default=",
+			Result: []Rule{
+				{Name: "default", Value: "", Values: []string{""}, HasValue: true, ValueOffset: 8},
+			},
+		},
+	}
+
+	for _, item := range cases {
+		rules, err := Parse(item.Tag)
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", item.Tag, err)
+			continue
+		}
+		if !reflect.DeepEqual(rules, item.Result) {
+			t.Errorf("[%s] results not match\nGot: %#v\nExpected: %#v", item.Tag, rules, item.Result)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		Tag    string
+		Offset int
+	}{
+		{Tag: "default='a,b", Offset: 12},
+		{Tag: "required,,min=1", Offset: 9},
+		{Tag: "required,", Offset: 9},
+		{Tag: "min 1", Offset: 4},
+		{Tag: "=1", Offset: 0},
+		{Tag: `default=a\`, Offset: 9},
+		{Tag: "default='a'b'", Offset: 13},
+	}
+
+	for _, item := range cases {
+		_, err := Parse(item.Tag)
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("[%s] expected syntax error, got %v", item.Tag, err)
+			continue
+		}
+		if syntaxErr.Offset != item.Offset {
+			t.Errorf("[%s] expected error at %d, got %v", item.Tag, item.Offset, syntaxErr)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	. "codegenhw/api_error"
+	"codegenhw/api_tag"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -10,22 +11,6 @@ import (
 	"strings"
 )
 
-func ParseParams(input string) map[string]string {
-	result := make(map[string]string)
-	pairs := strings.Split(input, ",")
-
-	for _, pair := range pairs {
-		parts := strings.SplitN(pair, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			result[key] = value
-		}
-	}
-
-	return result
-}
-
 func SetValue(field *reflect.Value, fieldName string, query url.Values) error {
 
 	queryValues, ok := query[fieldName]
@@ -52,56 +37,58 @@ func SetValue(field *reflect.Value, fieldName string, query url.Values) error {
 type ApiValidator struct {
 }
 
-func (a ApiValidator) apiValidatorStructTags() map[string]struct{} {
-	return map[string]struct{}{
-		"required":  {},
-		"paramname": {},
-		"enum":      {},
-		"default":   {},
-		"min":       {},
-		"max":       {},
-	}
-}
-
 // query=login=mr.moderator&age=32&status=moderator&full_name=Ivan_Ivanov
 // tag=`apivalidator:"enum=user|moderator|admin,default=user"`
 func (a ApiValidator) fillAndValidate(tag, fieldName string, field *reflect.Value, query url.Values) error {
 
-	if strings.Contains(tag, "paramname") {
-		fieldName = ParseParams(tag)["paramname"]
+	parsed, err := api_tag.Parse(tag)
+	if err != nil {
+		return ApiError{HTTPStatus: http.StatusInternalServerError, Err: err}
+	}
+	for _, rule := range parsed {
+		if _, ok := api_tag.Known[rule.Name]; !ok {
+			return ApiError{HTTPStatus: http.StatusInternalServerError, Err: fmt.Errorf("unknown apivalidator tag %v", rule.Name)}
+		}
+	}
+	rules := api_tag.Lookup(parsed)
+
+	if rule, ok := rules["paramname"]; ok {
+		fieldName = rule.Value
 	} else {
 		fieldName = strings.ToLower(fieldName)
 	}
 
-	err := SetValue(field, fieldName, query)
+	err = SetValue(field, fieldName, query)
 	if err != nil {
 		return err
 	}
 
-	if strings.Contains(tag, "required") && field.IsZero() {
+	if _, ok := rules["required"]; ok && field.IsZero() {
 		return ApiError{HTTPStatus: http.StatusBadRequest, Err: fmt.Errorf("%v must be not empty", fieldName)}
 	}
-	if strings.Contains(tag, "default") && field.IsZero() {
-		defaulValue := ParseParams(tag)["default"]
-		field.SetString(defaulValue)
+	if rule, ok := rules["default"]; ok && field.IsZero() {
+		err = SetValue(field, fieldName, url.Values{fieldName: {rule.Value}})
+		if err != nil {
+			return ApiError{HTTPStatus: http.StatusInternalServerError, Err: fmt.Errorf("bad default for %v: %v", fieldName, err)}
+		}
 	}
 
-	if strings.Contains(tag, "enum") {
-		tag, _ = strings.CutPrefix(tag, "enum=")
-		tag, _, _ = strings.Cut(tag, ",")
-		enumValues := strings.Split(tag, "|")
+	if rule, ok := rules["enum"]; ok {
 		isValidEnum := false
-		for _, v := range enumValues {
-			if v == field.String() {
+		for _, v := range rule.Values {
+			if v == fmt.Sprint(field.Interface()) {
 				isValidEnum = true
 			}
 		}
 		if !isValidEnum {
-			return ApiError{HTTPStatus: http.StatusBadRequest, Err: fmt.Errorf("%v must be one of [%v]", fieldName, strings.Join(enumValues, ", "))}
+			return ApiError{HTTPStatus: http.StatusBadRequest, Err: fmt.Errorf("%v must be one of [%v]", fieldName, strings.Join(rule.Values, ", "))}
 		}
 	}
-	if strings.Contains(tag, "min") {
-		min, _ := strconv.Atoi(ParseParams(tag)["min"])
+	if rule, ok := rules["min"]; ok {
+		min, err := strconv.Atoi(rule.Value)
+		if err != nil {
+			return ApiError{HTTPStatus: http.StatusInternalServerError, Err: fmt.Errorf("bad min for %v: %v", fieldName, err)}
+		}
 		switch field.Type().Kind() {
 		case reflect.Int:
 			if int(field.Int()) < min {
@@ -113,8 +100,11 @@ func (a ApiValidator) fillAndValidate(tag, fieldName string, field *reflect.Valu
 			}
 		}
 	}
-	if strings.Contains(tag, "max") {
-		max, _ := strconv.Atoi(ParseParams(tag)["max"])
+	if rule, ok := rules["max"]; ok {
+		max, err := strconv.Atoi(rule.Value)
+		if err != nil {
+			return ApiError{HTTPStatus: http.StatusInternalServerError, Err: fmt.Errorf("bad max for %v: %v", fieldName, err)}
+		}
 		switch field.Type().Kind() {
 		case reflect.Int:
 			if int(field.Int()) > max {
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+type validatorTagsParams struct {
+	AdminID string `apivalidator:"paramname=admin_id"`
+	Mode    string `apivalidator:"default=maximum"`
+	Pair    string `apivalidator:"enum='a,b'|c,default='a,b'"`
+	Limit   int    `apivalidator:"default=5,min=1,max=10"`
+}
+
+func TestApiValidatorTags(t *testing.T) {
+	var validator ApiValidator
+	var params validatorTagsParams
+
+	err := validator.Decode(&params, url.Values{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := validatorTagsParams{Mode: "maximum", Pair: "a,b", Limit: 5}
+	if params != expected {
+		t.Errorf("results not match\nGot: %#v\nExpected: %#v", params, expected)
+	}
+
+	params = validatorTagsParams{}
+	err = validator.Decode(&params, url.Values{"admin_id": {"7"}, "pair": {"c"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if params.AdminID != "7" || params.Pair != "c" {
+		t.Errorf("unexpected result %#v", params)
+	}
+
+	params = validatorTagsParams{}
+	err = validator.Decode(&params, url.Values{"pair": {"a"}})
+	if err == nil || err.Error() != "pair must be one of [a,b, c]" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,716 @@
+package apigen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	api, diag, err := Load("testdata/basic", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diag.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diag.list)
+	}
+
+	if len(api.Services) != 1 || api.Services[0].Name != "Api" {
+		t.Fatalf("unexpected services %#v", api.Services)
+	}
+	endpoints := api.Services[0].Endpoints
+	if len(endpoints) != 2 {
+		t.Fatalf("expected 2 endpoints, got %d", len(endpoints))
+	}
+	create := endpoints[1]
+	if create.MethodName != "Create" || create.URL != "/user/create" || !reflect.DeepEqual(create.HTTPMethods, []string{"POST"}) || !create.Auth {
+		t.Errorf("unexpected endpoint %#v", create)
+	}
+	if create.Result.TypeName != "*User" || create.Params.TypeName != "CreateParams" {
+		t.Errorf("unexpected types %v, %v", create.Result.TypeName, create.Params.TypeName)
+	}
+
+	var names []string
+	for _, field := range create.Params.Fields {
+		names = append(names, field.ParamName)
+	}
+	if expected := []string{"login", "full_name", "status", "age"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("param names not match\nGot: %v\nExpected: %v", names, expected)
+	}
+
+	status := create.Params.Fields[2].Rules
+	if status.Default == nil || *status.Default != "user" || !reflect.DeepEqual(status.Enum, []string{"user", "moderator", "admin"}) {
+		t.Errorf("unexpected status rules %#v", status)
+	}
+	age := create.Params.Fields[3].Rules
+	if age.Min == nil || *age.Min != 0 || age.Max == nil || *age.Max != 128 {
+		t.Errorf("unexpected age rules %#v", age)
+	}
+}
+
+func TestHTTPEmitter(t *testing.T) {
+	api, _, err := Load("testdata/basic", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := (HTTPEmitter{}).Emit(&out, api); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "", out.Bytes(), 0); err != nil {
+		t.Fatalf("generated code does not parse: %v", err)
+	}
+
+	src := out.String()
+	for _, expected := range []string{
+		"// Code generated by codegen; DO NOT EDIT.",
+		"func unpackCreateParams(query url.Values) (CreateParams, error) {",
+		`fmt.Errorf("login len must be >= 10")`,
+		`case "/user/profile":`,
+		"func (s *Api) ServeHTTP(w http.ResponseWriter, r *http.Request) {",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("generated code has no %q", expected)
+		}
+	}
+	if strings.Contains(src, "ApiValidator") {
+		t.Errorf("generated code depends on ApiValidator")
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	_, diag, err := Load("testdata/invalid", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"testdata/invalid/api.go:8:26: field Params.A: unknown apivalidator tag \"requird\"",
+		"testdata/invalid/api.go:9:26: field Params.B: min=10 is greater than max=1",
+		"testdata/invalid/api.go:10:26: field Params.C: enum is supported only for string fields, not int",
+		"testdata/invalid/api.go:11:35: field Params.D: default=c is not one of enum values",
+		"testdata/invalid/api.go:12:2: field Params.E: unsupported type []int, only int and string are allowed",
+		"testdata/invalid/api.go:15:40: malformed apigen:api annotation: invalid character '}' in literal true (expecting 'e')",
+		"testdata/invalid/api.go:18:29: unknown apigen:api key \"methd\"",
+		"testdata/invalid/api.go:22:1: method Api.C: context.Context must be the first argument",
+		"testdata/invalid/api.go:28:2: field PathParams.ID: placeholder {id:int} needs an int field, got string",
+		"testdata/invalid/api.go:29:36: field PathParams.Page: source=body, expected query or path",
+		"testdata/invalid/api.go:30:2: field PathParams.Tab: source=path, but url /e/{id:int}/{name} of Api.E has no {tab}",
+		"testdata/invalid/api.go:34:1: url placeholder {name} of Api.E has no params field with source=path",
+		"testdata/invalid/api.go:36:27: bad url /f/x{id}: placeholder \"x{id}\" must be a whole path segment",
+		"testdata/invalid/api.go:39:47: unsupported method \"TRACE\", expected one of GET, POST, PUT, PATCH, DELETE, HEAD",
+		"testdata/invalid/api.go:39:56: method \"GET\" is listed twice",
+		"testdata/invalid/api.go:42:29: malformed apigen:api annotation: json: cannot unmarshal 1 into Go struct field annotation.method of type []string",
+		"testdata/invalid/api.go:45:1: warning: apigen:service annotation of I has no effect, the type has no apigen:api methods",
+		"testdata/invalid/api.go:45:20: unknown apigen:service key \"strict\"",
+		"testdata/invalid/api.go:48:31: bad prefix /j/: it must not end with /",
+		"testdata/invalid/api.go:48:37: method list is empty",
+		"testdata/invalid/api.go:54:29: status 302 is not a success status, expected 2xx",
+		"testdata/invalid/api.go:54:56: bad header name \"X Bad\"",
+		"testdata/invalid/api.go:54:84: header X-Dup is listed twice, as X-Dup and x-dup",
+		"testdata/invalid/api.go:58:1: method Api.L: result type map[string]chan int can not be encoded as json, it has chan int",
+		"testdata/invalid/api.go:61:1: method Api.M must return error as the last result",
+		"testdata/invalid/api.go:64:1: method Api.N: the second of three results must be *api_response.Meta, got *Params",
+		"testdata/invalid/api.go:66:29: method Api.Q returns a result, but status 204 has no body",
+		"testdata/invalid/api.go:70:1: method Api.R: argument type Missing is not defined",
+		"testdata/invalid/api.go:70:43: warning: undefined: Missing",
+		"testdata/invalid/api.go:73:1: method Api.S: result type []*Unknown is not defined",
+		"testdata/invalid/api.go:73:44: warning: undefined: Unknown",
+		"testdata/invalid/api.go:76:1: method T is already annotated at testdata/invalid/api.go:75:1",
+		"testdata/invalid/auth.go:10:2: field O.B: O has an api_auth.Authenticator in A already",
+		"testdata/invalid/auth.go:16:44: roles require auth, but auth is false",
+		"testdata/invalid/auth.go:16:59: role is empty",
+		"testdata/invalid/auth.go:16:63: role \"a\" is listed twice",
+	}
+	var out bytes.Buffer
+	diag.Print(&out)
+	if got := strings.TrimSpace(out.String()); got != strings.Join(expected, "\n") {
+		t.Errorf("diagnostics not match\nGot:\n%v\nExpected:\n%v", got, strings.Join(expected, "\n"))
+	}
+}
+
+func TestIREmitter(t *testing.T) {
+	api, _, err := Load("testdata/basic", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := (IREmitter{}).Emit(&out, api); err != nil {
+		t.Fatal(err)
+	}
+	var doc IRDocument
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("cant unpack json: %v", err)
+	}
+
+	if doc.Version != IRVersion || doc.PkgPath != "codegenhw/apigen/testdata/basic" {
+		t.Errorf("unexpected document header %v %v", doc.Version, doc.PkgPath)
+	}
+	profile := doc.Services[0].Endpoints[0]
+	if !reflect.DeepEqual(profile.HTTPMethods, []string{"GET", "POST"}) || profile.Response.Type != "*User" {
+		t.Errorf("unexpected endpoint %#v", profile)
+	}
+	name := doc.Services[0].Endpoints[1].Params.Fields[1]
+	if name.Name != "Name" || name.Param != "full_name" || name.Tag != "paramname=full_name" {
+		t.Errorf("unexpected field %#v", name)
+	}
+
+	// positions do not depend on how the package is given
+	absDir, err := filepath.Abs("testdata/basic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	absAPI, _, err := Load(absDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var absOut bytes.Buffer
+	if err := (IREmitter{}).Emit(&absOut, absAPI); err != nil {
+		t.Fatal(err)
+	}
+	if position := profile.Position; position != "api.go:23:1" || absOut.String() != out.String() {
+		t.Errorf("document depends on the package path, position %v", position)
+	}
+}
+
+func TestTemplateOverride(t *testing.T) {
+	api, _, err := Load("testdata/basic", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "http"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	auth := `{{define "auth"}}
+	if r.Header.Get("Authorization") == "" {
+		WriteError(w, {{apiError 401 "no token"}})
+		return
+	}
+{{- end}}`
+	if err := os.WriteFile(filepath.Join(dir, "http", "auth.tmpl"), []byte(auth), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := (HTTPEmitter{Options{TemplateDir: dir}}).Emit(&out, api); err != nil {
+		t.Fatal(err)
+	}
+	src := out.String()
+	// the embedded template calls s.authenticate, the override does not
+	if !strings.Contains(src, `ApiError{HTTPStatus: 401, Err: fmt.Errorf("no token")}`) || strings.Contains(src, "s.authenticate(w, r") {
+		t.Errorf("auth template is not overridden:\n%v", src)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "http", "auth.tmpl"), []byte(`{{define "auth"}}{{.NoSuchField}}{{end}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = (HTTPEmitter{Options{TemplateDir: dir}}).Emit(&out, api)
+	if err == nil || !strings.Contains(err.Error(), "auth.tmpl") {
+		t.Errorf("expected error in auth.tmpl, got %v", err)
+	}
+}
+
+func TestBackends(t *testing.T) {
+	api, _, err := Load("testdata/basic", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, backend := range Backends() {
+		names = append(names, backend.Name)
+
+		var out bytes.Buffer
+		if err := backend.New(Options{}).Emit(&out, api); err != nil {
+			t.Errorf("%v: %v", backend.Name, err)
+			continue
+		}
+		switch {
+		case strings.HasSuffix(backend.FileName, ".go"):
+			if _, err := parser.ParseFile(token.NewFileSet(), "", out.Bytes(), 0); err != nil {
+				t.Errorf("%v: generated code does not parse: %v", backend.Name, err)
+			}
+		case strings.HasSuffix(backend.FileName, ".json"):
+			if !json.Valid(out.Bytes()) {
+				t.Errorf("%v: invalid json", backend.Name)
+			}
+		}
+	}
+	if expected := []string{"client", "docs", "http", "ir", "openapi"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("backends not match\nGot: %v\nExpected: %v", names, expected)
+	}
+}
+
+func TestOpenAPIEmitter(t *testing.T) {
+	api, _, err := Load("testdata/basic", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := (OpenAPIEmitter{}).Emit(&out, api); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Description string `json:"description"`
+			Parameters  []struct {
+				Name     string `json:"name"`
+				Required bool   `json:"required"`
+			} `json:"parameters"`
+			RequestBody *struct {
+				Content map[string]struct {
+					Schema struct {
+						Properties map[string]map[string]any `json:"properties"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	profile := doc.Paths["/user/profile"]
+	if profile["get"].OperationID != "ApiProfileGet" || len(profile["get"].Parameters) != 1 || !profile["get"].Parameters[0].Required {
+		t.Errorf("unexpected profile operation %+v", profile["get"])
+	}
+	create := doc.Paths["/user/create"]["post"]
+	if create.RequestBody == nil {
+		t.Fatalf("create has no request body")
+	}
+	age := create.RequestBody.Content["application/x-www-form-urlencoded"].Schema.Properties["age"]
+	if age["type"] != "integer" || age["minimum"] != 0.0 || age["maximum"] != 128.0 {
+		t.Errorf("unexpected age schema %v", age)
+	}
+	if _, ok := doc.Components.Schemas["User"]; !ok {
+		t.Errorf("User schema is missing")
+	}
+	// the authenticator is not known until run time
+	if create.Description != "Requires authorization." || strings.Contains(out.String(), "security") {
+		t.Errorf("unexpected create security %q", create.Description)
+	}
+
+	// StrictApi and LegacyApi both serve /user
+	api, _, err = Load("testdata/service", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := (OpenAPIEmitter{}).Emit(&out, api); err != nil {
+		t.Fatal(err)
+	}
+	var shared struct {
+		Paths map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &shared); err != nil {
+		t.Fatal(err)
+	}
+	for _, service := range []string{"StrictApi", "LegacyApi"} {
+		item := shared.Paths["/"+service+"/user"]
+		if item == nil || item["description"] != "Served by the "+service+" handler at /user." {
+			t.Errorf("unexpected /user path item of %v: %v", service, item)
+		}
+	}
+	if _, ok := shared.Paths["/user"]; ok {
+		t.Errorf("/user is described for one of the services only")
+	}
+}
+
+func TestInputHash(t *testing.T) {
+	backend, _ := LookupBackend("http")
+	hash, err := InputHash("testdata/basic", "", backend, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := InputHash("testdata/basic", "", backend, Options{}); again != hash {
+		t.Errorf("hash is not stable: %v, %v", hash, again)
+	}
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "http"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "http", "auth.tmpl"), []byte(`{{define "auth"}}{{end}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if other, _ := InputHash("testdata/basic", "", backend, Options{TemplateDir: dir}); other == hash {
+		t.Errorf("template override does not change the hash")
+	}
+	docs, _ := LookupBackend("docs")
+	if other, _ := InputHash("testdata/basic", "", docs, Options{}); other == hash {
+		t.Errorf("backend does not change the hash")
+	}
+
+	api, _, err := Load("testdata/basic", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := backend.New(Options{InputHash: hash}).Emit(&out, api); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "api_handlers.go")
+	if err := os.WriteFile(output, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if recorded := ReadInputHash(output); recorded != hash {
+		t.Errorf("recorded hash %q, expected %q", recorded, hash)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), output, nil, 0); err != nil {
+		t.Errorf("generated code does not parse: %v", err)
+	}
+}
+
+func TestInputHashImports(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/app\n")
+	write("api/api.go", "package api\n\nimport (\n\t\"context\"\n\t\"example.com/app/models\"\n)\n\ntype Api struct{}\n\n// apigen:api {\"url\": \"/user\"}\nfunc (srv *Api) Get(ctx context.Context, in models.Params) error { return nil }\n")
+	write("models/models.go", "package models\n\nimport \"example.com/app/shared\"\n\ntype Params struct {\n\tName shared.Name\n}\n")
+	write("shared/shared.go", "package shared\n\ntype Name string\n")
+	write("other/other.go", "package other\n")
+
+	backend, _ := LookupBackend("http")
+	hash := func() string {
+		t.Helper()
+		hash, err := InputHash(filepath.Join(dir, "api"), "", backend, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	initial := hash()
+	write("other/other.go", "package other\n\nconst X = 1\n")
+	if hash() != initial {
+		t.Errorf("a package that is not imported changes the hash")
+	}
+	write("models/models.go", "package models\n\nimport \"example.com/app/shared\"\n\ntype Params struct {\n\tName shared.Name `apivalidator:\"required\"`\n}\n")
+	changed := hash()
+	if changed == initial {
+		t.Errorf("an imported params struct does not change the hash")
+	}
+	write("shared/shared.go", "package shared\n\ntype Name int\n")
+	if hash() == changed {
+		t.Errorf("a package imported by an imported one does not change the hash")
+	}
+}
+
+func TestPathParams(t *testing.T) {
+	api, diag, err := Load("testdata/path", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diag.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diag.list)
+	}
+
+	profile := api.Services[0].Endpoints[0]
+	if len(profile.PathParams) != 2 || *profile.PathParams[0] != (PathParam{Name: "id", Kind: "int", Placeholder: "{id:int}"}) {
+		t.Errorf("unexpected path params %v", profile.PathParams)
+	}
+	var sources []string
+	for _, field := range profile.Params.Fields {
+		sources = append(sources, field.Source)
+	}
+	if expected := []string{"path", "path", "query"}; !reflect.DeepEqual(sources, expected) {
+		t.Errorf("sources not match\nGot: %v\nExpected: %v", sources, expected)
+	}
+
+	// default=010 is ten, not an octal Go literal or an invalid JSON number
+	limit := api.Services[0].Endpoints[1].Params.Fields[0].Rules
+	if limit.Default == nil || *limit.Default != "10" {
+		t.Errorf("unexpected limit rules %#v", limit)
+	}
+	var out bytes.Buffer
+	if err := (OpenAPIEmitter{}).Emit(&out, api); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"default": 10`) {
+		t.Errorf("limit default is missing in\n%v", out.String())
+	}
+}
+
+func TestStrictHTTP(t *testing.T) {
+	api, diag, err := Load("testdata/service", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diag.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diag.list)
+	}
+
+	strict, legacy := api.Services[0], api.Services[1]
+	if !strict.StrictHTTP || legacy.StrictHTTP {
+		t.Fatalf("unexpected strict_http of %v and %v", strict.Name, legacy.Name)
+	}
+	if get := strict.Endpoints[0]; !reflect.DeepEqual(get.ServedMethods(), []string{"GET", "HEAD"}) || get.Allow() != "GET, HEAD, OPTIONS" {
+		t.Errorf("unexpected verbs of StrictApi.Get: %v, allow %q", get.ServedMethods(), get.Allow())
+	}
+	if create := strict.Endpoints[1]; create.Allow() != "POST, OPTIONS" {
+		t.Errorf("unexpected allow of StrictApi.Create: %q", create.Allow())
+	}
+	if get := legacy.Endpoints[0]; !reflect.DeepEqual(get.ServedMethods(), []string{"GET"}) {
+		t.Errorf("unexpected verbs of LegacyApi.Get: %v", get.ServedMethods())
+	}
+}
+
+func TestServiceSettings(t *testing.T) {
+	api, diag, err := Load("testdata/service", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diag.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diag.list)
+	}
+
+	admin := api.Services[2]
+	if admin.Name != "AdminApi" || admin.Prefix != "/admin" {
+		t.Fatalf("unexpected service %v with prefix %q", admin.Name, admin.Prefix)
+	}
+	ban, status := admin.Endpoints[0], admin.Endpoints[1]
+	if ban.URL != "/admin/ban" || !ban.Auth || !reflect.DeepEqual(ban.HTTPMethods, []string{"POST"}) {
+		t.Errorf("service settings are not applied to Ban: %v %v %v", ban.URL, ban.Auth, ban.HTTPMethods)
+	}
+	if status.URL != "/admin/status" || status.Auth || !reflect.DeepEqual(status.HTTPMethods, []string{"GET"}) {
+		t.Errorf("method settings do not override service ones in Status: %v %v %v", status.URL, status.Auth, status.HTTPMethods)
+	}
+}
+
+func TestResponseSettings(t *testing.T) {
+	api, diag, err := Load("testdata/response", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diag.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diag.list)
+	}
+
+	create, login := api.Services[0].Endpoints[0], api.Services[0].Endpoints[1]
+	if create.Status != 201 || len(create.Headers) != 1 || *create.Headers[0] != (Header{Name: "Cache-Control", Value: "no-store"}) || create.ReturnsMeta {
+		t.Errorf("unexpected response settings of Create: %v %v %v", create.Status, create.Headers, create.ReturnsMeta)
+	}
+	if login.Status != 200 || login.Headers != nil || !login.ReturnsMeta || login.Result.TypeName != "*User" {
+		t.Errorf("unexpected response settings of Login: %v %v %v", login.Status, login.Headers, login.ReturnsMeta)
+	}
+}
+
+func TestSignatures(t *testing.T) {
+	api, diag, err := Load("testdata/signatures", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diag.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diag.list)
+	}
+
+	var shapes []string
+	for _, endpoint := range api.Services[0].Endpoints {
+		shape := fmt.Sprintf("%v ctx=%v params=%v result=", endpoint.MethodName, endpoint.TakesContext, endpoint.Params != nil)
+		if endpoint.Result != nil {
+			shape += endpoint.Result.TypeName
+		}
+		shapes = append(shapes, fmt.Sprintf("%v meta=%v status=%v", shape, endpoint.ReturnsMeta, endpoint.Status))
+	}
+	expected := []string{
+		"Me ctx=true params=false result=*User meta=false status=200",
+		"Delete ctx=true params=true result= meta=false status=204",
+		"Get ctx=false params=true result=User meta=false status=200",
+		"List ctx=false params=false result=[]User meta=false status=200",
+		"Index ctx=true params=false result=map[string]int meta=true status=200",
+		"Ping ctx=false params=false result= meta=false status=204",
+		"Logout ctx=true params=false result= meta=true status=204",
+		"Refresh ctx=true params=false result=*User meta=true status=204",
+		"Touch ctx=false params=true result= meta=false status=204",
+		"Users ctx=false params=false result=*Page[User] meta=false status=200",
+		"Counts ctx=false params=false result=*Page[int] meta=false status=200",
+	}
+	if !reflect.DeepEqual(shapes, expected) {
+		t.Errorf("shapes not match\nGot:\n%v\nExpected:\n%v", strings.Join(shapes, "\n"), strings.Join(expected, "\n"))
+	}
+
+	// instances of a generic type are different schemas
+	users, counts := api.Schemas["PageUser"], api.Schemas["PageInt"]
+	if users == nil || counts == nil || users.Properties[0].Schema.Items.Ref != "User" || counts.Properties[0].Schema.Items.Type != "integer" {
+		t.Errorf("unexpected schemas of Page instances: %v", sortedKeys(api.Schemas))
+	}
+
+	checkCompiles(t, api, "testdata/signatures/api.go")
+}
+
+// checkCompiles type checks the generated handlers and client together with
+// the sources of api.
+func checkCompiles(t *testing.T, api *API, sources ...string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, backend := range []string{"http", "client"} {
+		var out bytes.Buffer
+		b, _ := LookupBackend(backend)
+		if err := b.New(Options{}).Emit(&out, api); err != nil {
+			t.Fatal(err)
+		}
+		file, err := parser.ParseFile(fset, backend+".go", out.Bytes(), 0)
+		if err != nil {
+			t.Fatalf("generated code does not parse: %v", err)
+		}
+		files = append(files, file)
+	}
+	for _, source := range sources {
+		file, err := parser.ParseFile(fset, source, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check(api.PkgPath, fset, files, nil); err != nil {
+		t.Errorf("generated code does not compile: %v", err)
+	}
+}
+
+func TestImportCollisions(t *testing.T) {
+	api, diag, err := Load("testdata/imports", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diag.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diag.list)
+	}
+
+	expected := map[string]string{
+		"codegenhw/apigen/testdata/imports/a/models": "models",
+		"codegenhw/apigen/testdata/imports/b/models": "models2",
+		"codegenhw/apigen/testdata/imports/url":      "url2",
+	}
+	if !reflect.DeepEqual(api.Imports, expected) {
+		t.Errorf("unexpected imports %v", api.Imports)
+	}
+	checkCompiles(t, api, "testdata/imports/api.go")
+}
+
+func TestAuthenticator(t *testing.T) {
+	api, diag, err := Load("testdata/auth", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diag.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diag.list)
+	}
+	if field := api.Services[0].AuthenticatorField; field != "Auth" {
+		t.Errorf("unexpected authenticator field of Api %q", field)
+	}
+	if field := api.Services[1].AuthenticatorField; field != "" {
+		t.Errorf("unexpected authenticator field of DefaultApi %q", field)
+	}
+
+	var out bytes.Buffer
+	if err := (HTTPEmitter{}).Emit(&out, api); err != nil {
+		t.Fatal(err)
+	}
+	src := out.String()
+	for _, expected := range []string{
+		"func (s Api) authenticator() api_auth.Authenticator {\n\tif s.Auth != nil {\n\t\treturn s.Auth\n\t}\n\treturn api_auth.Default\n}",
+		"func (s DefaultApi) authenticator() api_auth.Authenticator {\n\treturn api_auth.Default\n}",
+		"principal, ok := s.authenticate(w, r)\n",
+		"r = r.WithContext(context.WithValue(r.Context(), principalKey{}, principal))",
+		"func PrincipalFromContext(ctx context.Context) (*api_auth.Principal, bool) {",
+		`"codegenhw/api_auth"`,
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("generated code has no %q", expected)
+		}
+	}
+}
+
+func TestRoles(t *testing.T) {
+	api, diag, err := Load("testdata/auth", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diag.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diag.list)
+	}
+
+	var access []string
+	for _, endpoint := range api.Services[2].Endpoints {
+		access = append(access, fmt.Sprintf("%v auth=%v roles=%v", endpoint.MethodName, endpoint.Auth, endpoint.Roles))
+	}
+	expected := []string{
+		"Ban auth=true roles=[admin]",
+		"Report auth=true roles=[admin moderator]",
+		"Status auth=false roles=[]",
+	}
+	if !reflect.DeepEqual(access, expected) {
+		t.Errorf("access not match\nGot:\n%v\nExpected:\n%v", strings.Join(access, "\n"), strings.Join(expected, "\n"))
+	}
+
+	var out bytes.Buffer
+	if err := (HTTPEmitter{}).Emit(&out, api); err != nil {
+		t.Fatal(err)
+	}
+	src := out.String()
+	for _, expected := range []string{
+		`principal, ok := s.authenticate(w, r, "admin", "moderator")`,
+		"if len(roles) > 0 && !principal.HasAnyRole(roles...) {",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("generated code has no %q", expected)
+		}
+	}
+
+	out.Reset()
+	if err := (DocsEmitter{}).Emit(&out, api); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Requires authorization. Allowed for roles `admin`, `moderator`.") {
+		t.Errorf("docs do not list roles:\n%v", out.String())
+	}
+}
+
+// TestLoadConcurrent is meant for -race, packages of different directories
+// are loaded at once.
+func TestLoadConcurrent(t *testing.T) {
+	dirs := []string{"testdata/basic", "testdata/imports", "testdata/path", "testdata/service"}
+	errs := make(chan error, len(dirs))
+	for _, dir := range dirs {
+		go func() {
+			api, diag, err := Load(dir, "")
+			if err == nil && (diag.HasErrors() || len(api.Services) == 0) {
+				err = fmt.Errorf("%v: unexpected diagnostics %v", dir, diag.list)
+			}
+			errs <- err
+		}()
+	}
+	for range dirs {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
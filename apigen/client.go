@@ -0,0 +1,26 @@
+package apigen
+
+import "io"
+
+// ClientEmitter writes a Go client for every service: a {Service}Client type
+// with a method per endpoint taking the same params and returning the same
+// result as the annotated method. The code comes from the "client" template set.
+type ClientEmitter struct {
+	Options
+}
+
+func init() {
+	Register(Backend{
+		Name:        "client",
+		FileName:    "api_client.go",
+		Description: "Go client",
+		TemplateSet: "client",
+		New: func(opts Options) Emitter {
+			return ClientEmitter{opts}
+		},
+	})
+}
+
+func (e ClientEmitter) Emit(w io.Writer, api *API) error {
+	return executeGo(w, "client", e.Options, api)
+}
@@ -0,0 +1,63 @@
+package apigen
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+	"sort"
+)
+
+type Diagnostic struct {
+	Pos     token.Position
+	Message string
+	Warning bool
+}
+
+func (d Diagnostic) String() string {
+	if d.Warning {
+		return fmt.Sprintf("%v: warning: %v", d.Pos, d.Message)
+	}
+	return fmt.Sprintf("%v: %v", d.Pos, d.Message)
+}
+
+// Diagnostics collects every problem found in the input package, so that
+// one run of the generator reports all of them instead of the first one.
+type Diagnostics struct {
+	fset *token.FileSet
+	list []Diagnostic
+}
+
+func NewDiagnostics(fset *token.FileSet) *Diagnostics {
+	return &Diagnostics{fset: fset}
+}
+
+func (d *Diagnostics) Errorf(pos token.Pos, format string, args ...interface{}) {
+	d.list = append(d.list, Diagnostic{Pos: d.fset.Position(pos), Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *Diagnostics) Warnf(pos token.Pos, format string, args ...interface{}) {
+	d.list = append(d.list, Diagnostic{Pos: d.fset.Position(pos), Message: fmt.Sprintf(format, args...), Warning: true})
+}
+
+func (d *Diagnostics) HasErrors() bool {
+	for _, diag := range d.list {
+		if !diag.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes diagnostics ordered by position, one per line.
+func (d *Diagnostics) Print(w io.Writer) {
+	sort.SliceStable(d.list, func(i, j int) bool {
+		a, b := d.list[i].Pos, d.list[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+	for _, diag := range d.list {
+		fmt.Fprintln(w, diag)
+	}
+}
@@ -0,0 +1,28 @@
+package apigen
+
+import "io"
+
+// DocsEmitter writes Markdown reference of the endpoints, their parameters
+// and result types. The text comes from the "docs" template set.
+type DocsEmitter struct {
+	Options
+}
+
+func init() {
+	Register(Backend{
+		Name:        "docs",
+		FileName:    "API.md",
+		Description: "Markdown documentation",
+		TemplateSet: "docs",
+		New: func(opts Options) Emitter {
+			return DocsEmitter{opts}
+		},
+	})
+}
+
+func (e DocsEmitter) Emit(w io.Writer, api *API) error {
+	if _, err := w.Write(hashComment("<!-- %v -->", e.InputHash)); err != nil {
+		return err
+	}
+	return execute(w, "docs", e.Options, api)
+}
@@ -0,0 +1,61 @@
+package apigen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Emitter writes one artifact generated from the model.
+type Emitter interface {
+	Emit(w io.Writer, api *API) error
+}
+
+// Options are the settings shared by all backends.
+type Options struct {
+	// TemplateDir overrides files of embedded template sets, see LoadTemplates
+	TemplateDir string
+	// InputHash is recorded in the header of formats with comments, see InputHash
+	InputHash string
+}
+
+// Backend is a registered emitter, handlers_gen selects backends by Name.
+type Backend struct {
+	Name string
+	// FileName is the default output file when several backends run at once
+	FileName string
+	// Description is a short help line
+	Description string
+	// TemplateSet is the template set the backend uses, if any
+	TemplateSet string
+	New         func(opts Options) Emitter
+}
+
+var backends = make(map[string]Backend)
+
+// Register makes a backend available by its name, it is meant to be called
+// from init functions and panics when the name is already taken.
+func Register(backend Backend) {
+	if _, exists := backends[backend.Name]; exists {
+		panic(fmt.Sprintf("apigen: backend %q is registered twice", backend.Name))
+	}
+	backends[backend.Name] = backend
+}
+
+// LookupBackend returns the backend registered under name.
+func LookupBackend(name string) (Backend, bool) {
+	backend, ok := backends[name]
+	return backend, ok
+}
+
+// Backends returns all registered backends ordered by name.
+func Backends() []Backend {
+	res := make([]Backend, 0, len(backends))
+	for _, backend := range backends {
+		res = append(res, backend)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
@@ -0,0 +1,95 @@
+package apigen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"sort"
+)
+
+const generatedHeader = "// Code generated by codegen; DO NOT EDIT.\n"
+
+// stdImports maps package names generated code may refer to onto import paths
+var stdImports = map[string]string{
+	"context": "context",
+	"errors":  "errors",
+	"json":    "encoding/json",
+	"fmt":     "fmt",
+	"io":      "io",
+	"http":    "net/http",
+	"url":     "net/url",
+	"strconv": "strconv",
+	"strings": "strings",
+}
+
+// moduleImports maps package names of the module runtime onto import paths
+var moduleImports = map[string]string{
+	"api_auth": authPkgPath,
+}
+
+// dotImports maps identifiers which come from dot imports onto import paths
+var dotImports = map[string]string{
+	"ApiError": "codegenhw/api_error",
+}
+
+// formatFile puts the header, package clause and the imports actually used by
+// body together and runs the result through gofmt. foreign holds packages of
+// user types referenced from body, path -> name, names are unique. A non-empty
+// hash is recorded in the header.
+func formatFile(pkgName string, body []byte, foreign map[string]string, hash string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", append([]byte("package "+pkgName+"\n"), body...), 0)
+	if err != nil {
+		return nil, fmt.Errorf("generated code is invalid: %v", err)
+	}
+
+	byName := make(map[string]string)
+	for importPath, name := range foreign {
+		byName[name] = importPath
+	}
+
+	imports := make(map[string]string)
+	for _, ident := range file.Unresolved {
+		if importPath, ok := byName[ident.Name]; ok {
+			imports[importPath] = ident.Name
+		} else if importPath, ok := stdImports[ident.Name]; ok {
+			imports[importPath] = ident.Name
+		} else if importPath, ok := moduleImports[ident.Name]; ok {
+			imports[importPath] = ident.Name
+		} else if importPath, ok := dotImports[ident.Name]; ok {
+			imports[importPath] = "."
+		}
+	}
+	paths := make([]string, 0, len(imports))
+	for importPath := range imports {
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+
+	var src bytes.Buffer
+	src.WriteString(generatedHeader)
+	src.Write(hashComment("// %v", hash))
+	src.WriteString("\n")
+	fmt.Fprintf(&src, "package %v\n\n", pkgName)
+	if len(paths) > 0 {
+		src.WriteString("import (\n")
+		for _, importPath := range paths {
+			if name := imports[importPath]; name != path.Base(importPath) {
+				fmt.Fprintf(&src, "\t%v %q\n", name, importPath)
+			} else {
+				fmt.Fprintf(&src, "\t%q\n", importPath)
+			}
+		}
+		src.WriteString(")\n\n")
+	}
+	src.Write(body)
+
+	res, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code is invalid: %v", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,386 @@
+package apigen
+
+import (
+	"bytes"
+	"codegenhw/api_auth"
+	"codegenhw/apigen/testdata/auth"
+	"codegenhw/apigen/testdata/path"
+	"codegenhw/apigen/testdata/response"
+	"codegenhw/apigen/testdata/service"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the generated handlers of testdata packages")
+
+// servedPackages are testdata packages with checked-in handlers, the tests
+// below send requests through them.
+var servedPackages = []string{"auth", "path", "response", "service"}
+
+func TestGeneratedHandlers(t *testing.T) {
+	for _, name := range servedPackages {
+		dir := filepath.Join("testdata", name)
+		output := filepath.Join(dir, "api_handlers.go")
+		api, diag, err := Load(dir, output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diag.HasErrors() {
+			t.Fatalf("unexpected diagnostics: %v", diag.list)
+		}
+		var out bytes.Buffer
+		if err := (HTTPEmitter{}).Emit(&out, api); err != nil {
+			t.Fatal(err)
+		}
+
+		if *update {
+			if err := os.WriteFile(output, out.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		current, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(current, out.Bytes()) {
+			t.Errorf("%v is out of date, run go test -run TestGeneratedHandlers -update", output)
+		}
+	}
+}
+
+// serveCase is a request to a generated handler and the expected response.
+type serveCase struct {
+	Method string
+	Path   string
+	// Body is sent form encoded
+	Body   string
+	Header http.Header
+	Status int
+	// Response is the whole expected body
+	Response string
+	// ResponseHeader lists headers the response must have, other headers are
+	// not checked
+	ResponseHeader http.Header
+}
+
+func checkServe(t *testing.T, handler http.Handler, cases []serveCase) {
+	t.Helper()
+	for i, item := range cases {
+		var body io.Reader
+		if item.Body != "" {
+			body = strings.NewReader(item.Body)
+		}
+		r := httptest.NewRequest(item.Method, item.Path, body)
+		if item.Body != "" {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		for name, values := range item.Header {
+			r.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != item.Status || w.Body.String() != item.Response {
+			t.Errorf("[%d] %v %v: expected %d %q, got %d %q", i, item.Method, item.Path, item.Status, item.Response, w.Code, w.Body.String())
+		}
+		for name, values := range item.ResponseHeader {
+			if got := w.Header().Values(name); strings.Join(got, "\n") != strings.Join(values, "\n") {
+				t.Errorf("[%d] %v %v: expected %v %q, got %q", i, item.Method, item.Path, name, values, got)
+			}
+		}
+	}
+}
+
+func TestServeStrictHTTP(t *testing.T) {
+	checkServe(t, &service.StrictApi{}, []serveCase{
+		{
+			Method:   "GET",
+			Path:     "/user?name=rvasily",
+			Status:   http.StatusOK,
+			Response: `{"error":"","response":{"name":"rvasily"}}`,
+		},
+		{
+			// net/http drops the body of HEAD responses itself
+			Method:         "HEAD",
+			Path:           "/user?name=rvasily",
+			Status:         http.StatusOK,
+			Response:       `{"error":"","response":{"name":"rvasily"}}`,
+			ResponseHeader: http.Header{"Content-Type": {"application/json"}},
+		},
+		{
+			Method:         "OPTIONS",
+			Path:           "/user",
+			Status:         http.StatusNoContent,
+			Response:       "",
+			ResponseHeader: http.Header{"Allow": {"GET, HEAD, OPTIONS"}},
+		},
+		{
+			Method:         "POST",
+			Path:           "/user",
+			Body:           "name=rvasily",
+			Status:         http.StatusMethodNotAllowed,
+			Response:       `{"error":"method not allowed"}`,
+			ResponseHeader: http.Header{"Allow": {"GET, HEAD, OPTIONS"}},
+		},
+		{
+			Method:         "GET",
+			Path:           "/user/create?name=rvasily",
+			Status:         http.StatusMethodNotAllowed,
+			Response:       `{"error":"method not allowed"}`,
+			ResponseHeader: http.Header{"Allow": {"POST, OPTIONS"}},
+		},
+		{
+			Method:   "GET",
+			Path:     "/unknown",
+			Status:   http.StatusNotFound,
+			Response: `{"error":"unknown method"}`,
+		},
+	})
+	checkServe(t, &service.LegacyApi{}, []serveCase{
+		{
+			Method:   "POST",
+			Path:     "/user",
+			Body:     "name=rvasily",
+			Status:   http.StatusNotAcceptable,
+			Response: `{"error":"bad method"}`,
+		},
+	})
+}
+
+func TestServeMount(t *testing.T) {
+	mux := http.NewServeMux()
+	admin := &service.AdminApi{}
+	mux.Handle("/admin/", admin)
+	mux.Handle("/v2/admin/", admin.Mount("/v2/admin"))
+
+	checkServe(t, mux, []serveCase{
+		{
+			Method:   "GET",
+			Path:     "/admin/status?name=rvasily",
+			Status:   http.StatusOK,
+			Response: `{"error":"","response":{"name":"rvasily"}}`,
+		},
+		{
+			Method:   "GET",
+			Path:     "/v2/admin/status?name=rvasily",
+			Status:   http.StatusOK,
+			Response: `{"error":"","response":{"name":"rvasily"}}`,
+		},
+		{
+			// the service settings make Ban a POST requiring authorization
+			Method:   "POST",
+			Path:     "/v2/admin/ban",
+			Body:     "name=rvasily",
+			Header:   http.Header{"X-Auth": {"100500"}},
+			Status:   http.StatusOK,
+			Response: `{"error":"","response":{"name":"rvasily"}}`,
+		},
+		{
+			Method:   "POST",
+			Path:     "/v2/admin/ban",
+			Body:     "name=rvasily",
+			Status:   http.StatusForbidden,
+			Response: `{"error":"unauthorized"}`,
+		},
+		{
+			Method:   "GET",
+			Path:     "/v2/admin/ban?name=rvasily",
+			Header:   http.Header{"X-Auth": {"100500"}},
+			Status:   http.StatusNotAcceptable,
+			Response: `{"error":"bad method"}`,
+		},
+		{
+			Method:   "GET",
+			Path:     "/v2/admin/unknown",
+			Status:   http.StatusNotFound,
+			Response: `{"error":"unknown method"}`,
+		},
+	})
+}
+
+func TestServeResponse(t *testing.T) {
+	checkServe(t, &response.Api{}, []serveCase{
+		{
+			Method:         "POST",
+			Path:           "/user/create",
+			Body:           "name=rvasily",
+			Status:         http.StatusCreated,
+			Response:       `{"error":"","response":{"name":"rvasily"}}`,
+			ResponseHeader: http.Header{"Cache-Control": {"no-store"}},
+		},
+		{
+			// headers are set on success only
+			Method:         "POST",
+			Path:           "/user/create",
+			Status:         http.StatusBadRequest,
+			Response:       `{"error":"name must be not empty"}`,
+			ResponseHeader: http.Header{"Cache-Control": nil},
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/login",
+			Body:     "name=rvasily",
+			Status:   http.StatusOK,
+			Response: `{"error":"","response":{"name":"rvasily"}}`,
+			ResponseHeader: http.Header{
+				"Location":   {"/user/rvasily"},
+				"Set-Cookie": {"session=rvasily"},
+			},
+		},
+		{
+			// the status of the metadata wins, static headers stay
+			Method:         "POST",
+			Path:           "/user/accept",
+			Body:           "name=rvasily",
+			Status:         http.StatusAccepted,
+			Response:       `{"error":"","response":{"name":"rvasily"}}`,
+			ResponseHeader: http.Header{"X-Queue": {"users"}},
+		},
+		{
+			// only a nil pointer is left out, an empty list is a result
+			Method:   "GET",
+			Path:     "/user/search",
+			Status:   http.StatusOK,
+			Response: `{"error":"","response":[]}`,
+		},
+	})
+}
+
+func TestServePathParams(t *testing.T) {
+	checkServe(t, &path.Api{}, []serveCase{
+		{
+			Method:   "GET",
+			Path:     "/user/42/posts?format=xml",
+			Status:   http.StatusOK,
+			Response: `{"error":"","response":{"id":42,"tab":"posts/xml"}}`,
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/list",
+			Status:   http.StatusOK,
+			Response: `{"error":"","response":{"id":10,"tab":""}}`,
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/0/info",
+			Status:   http.StatusBadRequest,
+			Response: `{"error":"id must be \u003e= 1"}`,
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/42/likes",
+			Status:   http.StatusBadRequest,
+			Response: `{"error":"tab must be one of [info, posts]"}`,
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/rvasily/info",
+			Status:   http.StatusNotFound,
+			Response: `{"error":"unknown method"}`,
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/42/info/more",
+			Status:   http.StatusNotFound,
+			Response: `{"error":"unknown method"}`,
+		},
+	})
+}
+
+func TestServeRoles(t *testing.T) {
+	// AdminApi has no authenticator of its own
+	defer func(prev api_auth.Authenticator) { api_auth.Default = prev }(api_auth.Default)
+	api_auth.Default = api_auth.AuthenticatorFunc(func(r *http.Request) (*api_auth.Principal, error) {
+		if r.Header.Get("X-User") == "" {
+			return api_auth.HeaderToken{Header: "X-User", Token: "rvasily"}.Authenticate(r)
+		}
+		return &api_auth.Principal{ID: r.Header.Get("X-User"), Roles: r.Header.Values("X-Role")}, nil
+	})
+
+	checkServe(t, &auth.AdminApi{}, []serveCase{
+		{
+			Method: "GET",
+			Path:   "/admin/ban?name=rvasily",
+			Header: http.Header{"X-User": {"rvasily"}, "X-Role": {"admin"}},
+			Status: http.StatusNoContent,
+		},
+		{
+			Method:   "GET",
+			Path:     "/admin/ban?name=rvasily",
+			Header:   http.Header{"X-User": {"rvasily"}, "X-Role": {"moderator"}},
+			Status:   http.StatusForbidden,
+			Response: `{"error":"forbidden: role admin required"}`,
+		},
+		{
+			Method: "GET",
+			Path:   "/admin/report?name=rvasily",
+			Header: http.Header{"X-User": {"rvasily"}, "X-Role": {"user", "moderator"}},
+			Status: http.StatusNoContent,
+		},
+		{
+			Method:   "GET",
+			Path:     "/admin/report?name=rvasily",
+			Header:   http.Header{"X-User": {"rvasily"}},
+			Status:   http.StatusForbidden,
+			Response: `{"error":"forbidden: role admin or moderator required"}`,
+		},
+		{
+			Method:   "GET",
+			Path:     "/admin/report?name=rvasily",
+			Status:   http.StatusForbidden,
+			Response: `{"error":"unauthorized"}`,
+		},
+		{
+			Method: "GET",
+			Path:   "/admin/status",
+			Status: http.StatusNoContent,
+		},
+	})
+}
+
+func TestServeAuthenticator(t *testing.T) {
+	nobody := api_auth.AuthenticatorFunc(func(r *http.Request) (*api_auth.Principal, error) {
+		return nil, nil
+	})
+	checkServe(t, &auth.Api{Auth: nobody}, []serveCase{
+		{
+			Method:   "GET",
+			Path:     "/user?name=rvasily",
+			Status:   http.StatusUnauthorized,
+			Response: `{"error":"not authenticated"}`,
+		},
+	})
+	checkServe(t, &auth.Api{Auth: api_auth.HeaderToken{Header: "X-Token", Token: "secret"}}, []serveCase{
+		{
+			Method:   "GET",
+			Path:     "/user?name=rvasily",
+			Header:   http.Header{"X-Token": {"secret"}},
+			Status:   http.StatusOK,
+			Response: `{"error":"","response":{"name":"rvasily"}}`,
+		},
+		{
+			// the default token is not accepted by the service authenticator
+			Method:   "GET",
+			Path:     "/user?name=rvasily",
+			Header:   http.Header{"X-Auth": {"100500"}},
+			Status:   http.StatusForbidden,
+			Response: `{"error":"unauthorized"}`,
+		},
+	})
+	checkServe(t, &auth.DefaultApi{}, []serveCase{
+		{
+			Method:   "GET",
+			Path:     "/user?name=rvasily",
+			Header:   http.Header{"X-Auth": {"100500"}},
+			Status:   http.StatusOK,
+			Response: `{"error":"","response":{"name":"rvasily"}}`,
+		},
+	})
+}
@@ -0,0 +1,175 @@
+package apigen
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"hash"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is the generator version. It is a part of the input hash, so it has
+// to be bumped when a change of the generator itself, not of its templates,
+// changes the output.
+const Version = "4"
+
+// inputHashMarker starts the header line holding the input hash
+const inputHashMarker = "apigen:inputs "
+
+// InputHash returns a hash of everything the output of backend depends on:
+// the sources of the package at path except generated ones and output, the
+// generator version, the backend and its template set. Packages of the same
+// module imported by the sources, directly or not, are covered as they may
+// hold params types, packages of other modules are not.
+func InputHash(path, output string, backend Backend, opts Options) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "version %v\nbackend %v\n", Version, backend.Name)
+
+	if backend.TemplateSet != "" {
+		files, err := templateFiles(backend.TemplateSet, opts.TemplateDir)
+		if err != nil {
+			return "", err
+		}
+		for _, name := range sortedKeys(files) {
+			writeInput(h, "template "+name, files[name])
+		}
+	}
+
+	dir, filePaths, err := packageFiles(path)
+	if err != nil {
+		return "", err
+	}
+	if dir != path {
+		// in file mode only this file is scanned for annotations
+		fmt.Fprintf(h, "input %v\n", filepath.Base(path))
+	}
+	outputPath, _ := filepath.Abs(output)
+	imports := make(map[string]bool)
+	for _, filePath := range filePaths {
+		if absPath, _ := filepath.Abs(filePath); absPath == outputPath {
+			continue
+		}
+		src, err := os.ReadFile(filePath)
+		if err != nil {
+			return "", err
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), filePath, src, parser.ImportsOnly|parser.ParseComments)
+		if err == nil && ast.IsGenerated(file) {
+			continue
+		}
+		writeInput(h, "source "+filepath.Base(filePath), src)
+		addImports(imports, file)
+	}
+	if err := hashImports(h, dir, imports); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashImports hashes the sources of the packages of imports lying in the
+// module of dir and of the module packages they import in turn.
+func hashImports(h hash.Hash, dir string, imports map[string]bool) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	modDir, modPath, ok := findModule(absDir)
+	if !ok {
+		return nil
+	}
+	done := make(map[string]bool)
+	for {
+		var pending []string
+		for importPath := range imports {
+			if !done[importPath] {
+				pending = append(pending, importPath)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		sort.Strings(pending)
+		for _, importPath := range pending {
+			done[importPath] = true
+			rel, ok := strings.CutPrefix(importPath, modPath)
+			if !ok || rel != "" && rel[0] != '/' {
+				continue
+			}
+			_, filePaths, err := packageFiles(filepath.Join(modDir, filepath.FromSlash(rel)))
+			if err != nil {
+				// the type checker reports missing packages
+				continue
+			}
+			fmt.Fprintf(h, "import %v\n", importPath)
+			for _, filePath := range filePaths {
+				src, err := os.ReadFile(filePath)
+				if err != nil {
+					return err
+				}
+				writeInput(h, "source "+filepath.Base(filePath), src)
+				if file, err := parser.ParseFile(token.NewFileSet(), filePath, src, parser.ImportsOnly); err == nil {
+					addImports(imports, file)
+				}
+			}
+		}
+	}
+}
+
+func addImports(imports map[string]bool, file *ast.File) {
+	if file == nil {
+		return
+	}
+	for _, spec := range file.Imports {
+		if importPath, err := strconv.Unquote(spec.Path.Value); err == nil {
+			imports[importPath] = true
+		}
+	}
+}
+
+// writeInput hashes data with its name and length, so that content can not
+// move from one input to another unnoticed.
+func writeInput(h hash.Hash, name string, data []byte) {
+	fmt.Fprintf(h, "%v %d\n", name, len(data))
+	h.Write(data)
+}
+
+// ReadInputHash returns the input hash recorded in the header of a generated
+// file, or an empty string if there is none or the file can not be read.
+func ReadInputHash(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for i := 0; i < 5 && scanner.Scan(); i++ {
+		if _, value, ok := strings.Cut(scanner.Text(), inputHashMarker); ok {
+			if fields := strings.Fields(value); len(fields) > 0 {
+				return fields[0]
+			}
+		}
+	}
+	return ""
+}
+
+// hashComment returns the header line recording hash in a comment of the
+// given form, e.g. "// %v", or nothing for an empty hash.
+func hashComment(format, hash string) []byte {
+	if hash == "" {
+		return nil
+	}
+	var res bytes.Buffer
+	fmt.Fprintf(&res, format, inputHashMarker+hash)
+	res.WriteByte('\n')
+	return res.Bytes()
+}
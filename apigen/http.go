@@ -0,0 +1,26 @@
+package apigen
+
+import "io"
+
+// HTTPEmitter writes net/http handlers: ServeHTTP for every service, a
+// wrapper per endpoint and a reflection-free unpack function per params struct.
+// The code comes from the "http" template set, see LoadTemplates.
+type HTTPEmitter struct {
+	Options
+}
+
+func init() {
+	Register(Backend{
+		Name:        "http",
+		FileName:    "api_handlers.go",
+		Description: "net/http handlers",
+		TemplateSet: "http",
+		New: func(opts Options) Emitter {
+			return HTTPEmitter{opts}
+		},
+	})
+}
+
+func (e HTTPEmitter) Emit(w io.Writer, api *API) error {
+	return executeGo(w, "http", e.Options, api)
+}
@@ -0,0 +1,224 @@
+// Package apigen finds methods marked with `// apigen:api {...}` in a Go
+// package, builds a model of them and generates code from the model.
+//
+// The pipeline is Load (parse and type check) -> API (the intermediate
+// representation below) -> Emitter (writes an artifact to an io.Writer).
+// Emitters only see the API, so new ones can be written without touching
+// the parser.
+package apigen
+
+import (
+	"go/token"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// API is the model of one Go package.
+type API struct {
+	// Package is the name of the package, generated code goes to the same one
+	Package string
+	// PkgPath is the import path of the package
+	PkgPath string
+	// Services are types with annotated methods in source order
+	Services []*Service
+	// Params are the distinct params structs in order of first use
+	Params []*Params
+	// Schemas are the named structs used by results, see Schema.Ref
+	Schemas map[string]*Schema
+	// Imports are packages of user types referenced by TypeName fields, path -> name
+	Imports map[string]string
+
+	Fset *token.FileSet
+}
+
+// HasPathParams tells whether some endpoint url has placeholders.
+func (api *API) HasPathParams() bool {
+	for _, service := range api.Services {
+		for _, endpoint := range service.Endpoints {
+			if len(endpoint.PathParams) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasAuth tells whether some endpoint has to be authorized.
+func (api *API) HasAuth() bool {
+	for _, service := range api.Services {
+		if service.HasAuth() {
+			return true
+		}
+	}
+	return false
+}
+
+// Service is a type with apigen:api methods, ServeHTTP is generated for it.
+type Service struct {
+	Name      string
+	Endpoints []*Endpoint
+	// Prefix is the base path from the apigen:service annotation, it is
+	// already a part of the endpoint urls
+	Prefix string
+	// StrictHTTP is set by apigen:service {"strict_http": true}: wrong verbs
+	// get 405 with an Allow header instead of 406, OPTIONS is answered and
+	// GET endpoints serve HEAD too
+	StrictHTTP bool
+	// AuthenticatorField is the api_auth.Authenticator field of the type,
+	// handlers fall back to api_auth.Default when it is empty or nil
+	AuthenticatorField string
+	Pos                token.Pos
+}
+
+// HasAuth tells whether some endpoint has to be authorized.
+func (s *Service) HasAuth() bool {
+	for _, endpoint := range s.Endpoints {
+		if endpoint.Auth {
+			return true
+		}
+	}
+	return false
+}
+
+// Endpoint is one annotated method.
+type Endpoint struct {
+	// Service is the type the method belongs to
+	Service *Service
+	// MethodName is the Go method called by the handler
+	MethodName string
+	// URL is the path the endpoint is served at including the service prefix,
+	// it may contain placeholders
+	URL string
+	// PathParams are the placeholders of URL in order
+	PathParams []*PathParam
+	// HTTPMethods are the allowed verbs, GET and POST when the annotation
+	// has none
+	HTTPMethods []string
+	// Auth tells whether the request has to be authorized
+	Auth bool
+	// Roles are the roles of which the principal needs at least one, Auth is
+	// set when there are any
+	Roles []string
+	// Status is the success status. Unless the annotation sets it, it is 200,
+	// or 204 for methods without a result
+	Status int
+	// Headers are the static headers of successful responses, sorted by name
+	Headers []*Header
+	// TakesContext is set for methods with a context.Context argument
+	TakesContext bool
+	// Params is nil for methods without a params struct argument
+	Params *Params
+	// Result is nil for methods returning only error, and metadata
+	Result *Result
+	// ReturnsMeta is set for methods returning *api_response.Meta before
+	// error
+	ReturnsMeta bool
+	Pos         token.Pos
+}
+
+// Header is a static response header from the annotation.
+type Header struct {
+	// Name is in canonical form, e.g. "Cache-Control"
+	Name  string
+	Value string
+}
+
+// BodyMethods returns the allowed verbs whose requests carry parameters in
+// the body, the others carry them in the url query.
+func (e *Endpoint) BodyMethods() []string {
+	var res []string
+	for _, method := range e.HTTPMethods {
+		if hasBody(method) {
+			res = append(res, method)
+		}
+	}
+	return res
+}
+
+// ServedMethods returns the verbs the handler calls the method for: the
+// allowed ones and, for strict services, HEAD when GET is allowed.
+func (e *Endpoint) ServedMethods() []string {
+	res := e.HTTPMethods
+	if e.Service.StrictHTTP && slices.Contains(res, http.MethodGet) && !slices.Contains(res, http.MethodHead) {
+		res = append(slices.Clone(res), http.MethodHead)
+	}
+	return res
+}
+
+// Allow returns the value of the Allow header strict services answer with.
+func (e *Endpoint) Allow() string {
+	return strings.Join(append(slices.Clone(e.ServedMethods()), http.MethodOptions), ", ")
+}
+
+func hasBody(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+}
+
+// PathParam is a "{name}" or "{name:kind}" segment of an endpoint url.
+type PathParam struct {
+	Name string
+	// Kind is the type hint, "int" or "string" when there is none
+	Kind string
+	// Placeholder is the segment as written in the url
+	Placeholder string
+}
+
+// Params is a struct filled from request parameters.
+type Params struct {
+	// TypeName is the type as written in generated code, e.g. "CreateParams" or "pkg.Params"
+	TypeName string
+	// Name is unique across the package and is used to name generated helpers
+	Name   string
+	Fields []*Field
+	Pos    token.Pos
+}
+
+// HasPathFields tells whether some fields come from the url path.
+func (p *Params) HasPathFields() bool {
+	for _, field := range p.Fields {
+		if field.Source == "path" {
+			return true
+		}
+	}
+	return false
+}
+
+// Field is a params struct field with its apivalidator rules.
+type Field struct {
+	Name string
+	// Kind is the underlying type, "int" or "string"
+	Kind string
+	// TypeName is the field type as written in generated code
+	TypeName string
+	// ParamName is the request parameter the field is read from
+	ParamName string
+	// Source is where the parameter comes from: "query", the url query or the
+	// form body, or "path", a placeholder of the endpoint url
+	Source string
+	// Tag is the raw apivalidator tag
+	Tag   string
+	Rules Rules
+	Pos   token.Pos
+}
+
+// Rules are the parsed apivalidator rules of a field, nil pointers and
+// slices mean the rule is absent.
+type Rules struct {
+	Required bool
+	Default  *string
+	Enum     []string
+	Min      *int
+	Max      *int
+}
+
+// Result is the first result of an endpoint method.
+type Result struct {
+	// TypeName is the type as written in generated code, e.g. "*User"
+	TypeName string
+	// Pointer is set for pointer types, only a nil result is left out of
+	// the response
+	Pointer bool
+	// Schema is the json form of the result
+	Schema *Schema
+}
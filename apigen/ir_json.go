@@ -0,0 +1,179 @@
+package apigen
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+	"path/filepath"
+)
+
+// IRVersion is bumped on every incompatible change of the IR document.
+const IRVersion = 1
+
+// IRDocument is the stable json form of API written by IREmitter. Unlike API
+// it holds plain values instead of the shared *Params and *Service links and
+// token.Pos, positions are written as text relative to the package
+// directory, pointers are left only for optional rules. Field names do not
+// follow the Go code.
+type IRDocument struct {
+	Version  int         `json:"version"`
+	Package  string      `json:"package"`
+	PkgPath  string      `json:"pkg_path"`
+	Services []IRService `json:"services"`
+}
+
+type IRService struct {
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	StrictHTTP bool         `json:"strict_http"`
+	Endpoints  []IREndpoint `json:"endpoints"`
+}
+
+type IREndpoint struct {
+	// Method is the Go method name
+	Method      string   `json:"method"`
+	URL         string   `json:"url"`
+	HTTPMethods []string `json:"http_methods"`
+	Auth        bool     `json:"auth"`
+	// Roles are the roles of which the principal needs one
+	Roles    []string `json:"roles,omitempty"`
+	Params   IRParams `json:"params"`
+	Response IRType   `json:"response"`
+	// Status is the success status
+	Status int `json:"status"`
+	// Headers are the static response headers, name -> value
+	Headers map[string]string `json:"headers,omitempty"`
+	// Meta tells whether the method returns response metadata
+	Meta bool `json:"meta"`
+	// Position is file:line:column of the method, the file is relative to the
+	// package directory
+	Position string `json:"position"`
+}
+
+type IRParams struct {
+	// Type is empty for methods without a params struct
+	Type   string    `json:"type"`
+	Fields []IRField `json:"fields"`
+}
+
+type IRField struct {
+	Name string `json:"name"`
+	// Kind is "int" or "string"
+	Kind string `json:"kind"`
+	Type string `json:"type"`
+	// Param is the resolved request parameter name
+	Param string `json:"param"`
+	// Source is "query" or "path"
+	Source string  `json:"source"`
+	Tag    string  `json:"tag"`
+	Rules  IRRules `json:"rules"`
+}
+
+type IRRules struct {
+	Required bool     `json:"required"`
+	Default  *string  `json:"default,omitempty"`
+	Enum     []string `json:"enum,omitempty"`
+	Min      *int     `json:"min,omitempty"`
+	Max      *int     `json:"max,omitempty"`
+}
+
+type IRType struct {
+	// Type is empty for methods without a result
+	Type string `json:"type"`
+}
+
+// IREmitter writes the model as an IRDocument.
+type IREmitter struct{}
+
+func init() {
+	Register(Backend{
+		Name:        "ir",
+		FileName:    "api_ir.json",
+		Description: "the parsed model as json",
+		New: func(Options) Emitter {
+			return IREmitter{}
+		},
+	})
+}
+
+func (IREmitter) Emit(w io.Writer, api *API) error {
+	data, err := json.MarshalIndent(NewIRDocument(api), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func NewIRDocument(api *API) IRDocument {
+	doc := IRDocument{
+		Version:  IRVersion,
+		Package:  api.Package,
+		PkgPath:  api.PkgPath,
+		Services: []IRService{},
+	}
+	for _, service := range api.Services {
+		irService := IRService{Name: service.Name, Prefix: service.Prefix, StrictHTTP: service.StrictHTTP, Endpoints: []IREndpoint{}}
+		for _, endpoint := range service.Endpoints {
+			params := IRParams{Fields: []IRField{}}
+			var fields []*Field
+			if endpoint.Params != nil {
+				params.Type = endpoint.Params.TypeName
+				fields = endpoint.Params.Fields
+			}
+			for _, field := range fields {
+				params.Fields = append(params.Fields, IRField{
+					Name:   field.Name,
+					Kind:   field.Kind,
+					Type:   field.TypeName,
+					Param:  field.ParamName,
+					Source: field.Source,
+					Tag:    field.Tag,
+					Rules: IRRules{
+						Required: field.Rules.Required,
+						Default:  field.Rules.Default,
+						Enum:     field.Rules.Enum,
+						Min:      field.Rules.Min,
+						Max:      field.Rules.Max,
+					},
+				})
+			}
+
+			var response IRType
+			if endpoint.Result != nil {
+				response.Type = endpoint.Result.TypeName
+			}
+
+			var headers map[string]string
+			for _, header := range endpoint.Headers {
+				if headers == nil {
+					headers = make(map[string]string)
+				}
+				headers[header.Name] = header.Value
+			}
+
+			irService.Endpoints = append(irService.Endpoints, IREndpoint{
+				Method:      endpoint.MethodName,
+				URL:         endpoint.URL,
+				HTTPMethods: endpoint.HTTPMethods,
+				Auth:        endpoint.Auth,
+				Roles:       endpoint.Roles,
+				Params:      params,
+				Response:    response,
+				Status:      endpoint.Status,
+				Headers:     headers,
+				Meta:        endpoint.ReturnsMeta,
+				Position:    packagePosition(api.Fset.Position(endpoint.Pos)),
+			})
+		}
+		doc.Services = append(doc.Services, irService)
+	}
+	return doc
+}
+
+// packagePosition renders pos with the file name relative to the package
+// directory, so the document does not depend on how the package was given.
+func packagePosition(pos token.Position) string {
+	pos.Filename = filepath.Base(pos.Filename)
+	return pos.String()
+}
@@ -0,0 +1,237 @@
+package apigen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type packageInfo struct {
+	fset  *token.FileSet
+	files []*ast.File
+	pkg   *types.Package
+	info  *types.Info
+	// imports collects packages referenced by generated code, path -> name
+	imports     map[string]string
+	paramTypes  map[*Endpoint]*types.Var
+	resultTypes map[*Endpoint]types.Type
+	tagLits     map[token.Pos]*ast.BasicLit
+}
+
+// typeName renders t as it has to be written in the generated file and
+// remembers the imports it needs.
+func (p *packageInfo) typeName(t types.Type) string {
+	return types.TypeString(t, func(other *types.Package) string {
+		if other == p.pkg {
+			return ""
+		}
+		return p.importName(other)
+	})
+}
+
+// importName returns the name other is imported under. Packages sharing a
+// name with each other or with a package the generated code uses itself get
+// an alias with a number.
+func (p *packageInfo) importName(other *types.Package) string {
+	if name, ok := p.imports[other.Path()]; ok {
+		return name
+	}
+	taken := func(name string) bool {
+		if importPath, ok := stdImports[name]; ok && importPath != other.Path() {
+			return true
+		}
+		if importPath, ok := moduleImports[name]; ok && importPath != other.Path() {
+			return true
+		}
+		for _, used := range p.imports {
+			if used == name {
+				return true
+			}
+		}
+		return false
+	}
+	name := other.Name()
+	for i := 2; taken(name); i++ {
+		name = fmt.Sprintf("%v%d", other.Name(), i)
+	}
+	p.imports[other.Path()] = name
+	return name
+}
+
+// loadPackage parses every non-test .go file of the package in dir that matches
+// the current build constraints. The output file and files marked as generated
+// are skipped so that previous runs do not leak into the model. A path to a
+// single file is still accepted and parsed on its own.
+func loadPackage(fset *token.FileSet, path, output string, diag *Diagnostics) (*packageInfo, error) {
+	dir, filePaths, err := packageFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	isDir := dir == path
+	outputPath, _ := filepath.Abs(output)
+	inputPath, _ := filepath.Abs(path)
+
+	var files, generated []*ast.File
+	for _, filePath := range filePaths {
+		file, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		absPath, _ := filepath.Abs(filePath)
+		switch {
+		case absPath == outputPath || ast.IsGenerated(file):
+			generated = append(generated, file)
+			continue
+		case !isDir && absPath != inputPath:
+			// only the requested file is scanned for annotations, the rest of
+			// the package is needed for type checking
+			generated = append(generated, file)
+			continue
+		}
+		if len(files) > 0 && files[0].Name.Name != file.Name.Name {
+			return nil, fmt.Errorf("%v: found packages %v and %v", dir, files[0].Name.Name, file.Name.Name)
+		}
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%v: no Go files", path)
+	}
+
+	return typeCheck(fset, dir, files, generated, diag)
+}
+
+// packageFiles returns the directory of path, a package directory or a file
+// in it, and its non-test .go files matching the current build constraints.
+func packageFiles(path string) (string, []string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, err
+	}
+	dir := path
+	if !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	var res []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if ok, err := build.Default.MatchFile(dir, name); err != nil || !ok {
+			continue
+		}
+		res = append(res, filepath.Join(dir, name))
+	}
+	return dir, res, nil
+}
+
+// generatedNames are the package level declarations of the generated file
+// annotated code may refer to. Until the first run they are undefined, which
+// is not worth a warning.
+var generatedNames = map[string]bool{
+	"ErrorResponse":        true,
+	"WriteError":           true,
+	"PrincipalFromContext": true,
+}
+
+// buildDirMu guards build.Default.Dir, which typeCheck points at the package
+// for the source importer. Type checks of concurrent Load calls take turns.
+var buildDirMu sync.Mutex
+
+// typeCheck runs go/types over the package. Previously generated files take
+// part in checking so that code referring to generated declarations (ServeHTTP
+// and friends) resolves, but errors inside them are ignored: they are about to
+// be regenerated. Other errors are reported as warnings, the package is usually
+// incomplete until the handlers are generated.
+func typeCheck(fset *token.FileSet, dir string, files, generated []*ast.File, diag *Diagnostics) (*packageInfo, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	// imports are resolved relative to the package module, not to the working directory
+	buildDirMu.Lock()
+	defer buildDirMu.Unlock()
+	defer func(dir string) { build.Default.Dir = dir }(build.Default.Dir)
+	build.Default.Dir = absDir
+
+	isGenerated := make(map[string]bool)
+	for _, file := range generated {
+		isGenerated[fset.Position(file.Pos()).Filename] = true
+	}
+
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			typeErr, ok := err.(types.Error)
+			if !ok {
+				diag.Warnf(token.NoPos, "%v", err)
+				return
+			}
+			if name, ok := strings.CutPrefix(typeErr.Msg, "undefined: "); ok && generatedNames[name] {
+				return
+			}
+			if !isGenerated[fset.Position(typeErr.Pos).Filename] {
+				diag.Warnf(typeErr.Pos, "%v", typeErr.Msg)
+			}
+		},
+	}
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	pkg, _ := conf.Check(importPath(absDir, files[0].Name.Name), fset, append(files, generated...), info)
+
+	return &packageInfo{
+		fset:        fset,
+		files:       files,
+		pkg:         pkg,
+		info:        info,
+		imports:     make(map[string]string),
+		paramTypes:  make(map[*Endpoint]*types.Var),
+		resultTypes: make(map[*Endpoint]types.Type),
+	}, nil
+}
+
+// importPath derives the import path of dir from the closest go.mod, falling
+// back to the package name outside of modules.
+func importPath(dir, pkgName string) string {
+	modDir, modPath, ok := findModule(dir)
+	if !ok {
+		return pkgName
+	}
+	rel, _ := filepath.Rel(modDir, dir)
+	return path.Join(modPath, filepath.ToSlash(rel))
+}
+
+// findModule returns the directory and the module path of the closest go.mod
+// above the absolute dir.
+func findModule(dir string) (string, string, bool) {
+	for modDir := dir; ; modDir = filepath.Dir(modDir) {
+		data, err := os.ReadFile(filepath.Join(modDir, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if module, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+					return modDir, strings.Trim(strings.TrimSpace(module), `"`), true
+				}
+			}
+			return "", "", false
+		}
+		if filepath.Dir(modDir) == modDir {
+			return "", "", false
+		}
+	}
+}
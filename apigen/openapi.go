@@ -0,0 +1,255 @@
+package apigen
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIEmitter writes an OpenAPI 3.0 document of all services, operations
+// are tagged with the service name.
+//
+// Every service is a handler of its own and may serve the same url as
+// another one, such paths are written prefixed with /{Service} and their
+// description tells the url actually served.
+// Security schemes are left out: the api_auth.Authenticator is chosen at run
+// time, operations requiring authorization only say so in the description.
+type OpenAPIEmitter struct{}
+
+func init() {
+	Register(Backend{
+		Name:        "openapi",
+		FileName:    "openapi.json",
+		Description: "OpenAPI 3.0 document",
+		New: func(Options) Emitter {
+			return OpenAPIEmitter{}
+		},
+	})
+}
+
+const openAPIVersion = "3.0.3"
+
+// object keeps the document building terse, encoding/json sorts map keys so
+// the output is stable.
+type object = map[string]any
+
+func (OpenAPIEmitter) Emit(w io.Writer, api *API) error {
+	// endpoints of one service have distinct urls, so a counter above 1 means
+	// several services
+	servedBy := make(map[string]int)
+	for _, service := range api.Services {
+		for _, endpoint := range service.Endpoints {
+			servedBy[openAPIPath(endpoint)]++
+		}
+	}
+	paths := object{}
+	for _, service := range api.Services {
+		for _, endpoint := range service.Endpoints {
+			path := openAPIPath(endpoint)
+			item := object{}
+			if servedBy[path] > 1 {
+				item["description"] = "Served by the " + service.Name + " handler at " + path + "."
+				path = "/" + service.Name + path
+			}
+			for _, method := range endpoint.HTTPMethods {
+				item[strings.ToLower(method)] = openAPIOperation(endpoint, method)
+			}
+			paths[path] = item
+		}
+	}
+
+	schemas := object{
+		"ErrorResponse": object{
+			"type":       "object",
+			"properties": object{"error": object{"type": "string"}},
+			"required":   []string{"error"},
+		},
+	}
+	for name, schema := range api.Schemas {
+		schemas[name] = openAPISchema(schema)
+	}
+
+	doc := object{
+		"openapi": openAPIVersion,
+		"info": object{
+			"title":   api.Package + " API",
+			"version": "1.0.0",
+		},
+		"paths":      paths,
+		"components": object{"schemas": schemas},
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func openAPIOperation(endpoint *Endpoint, method string) object {
+	operationID := endpoint.Service.Name + endpoint.MethodName
+	if len(endpoint.HTTPMethods) > 1 {
+		operationID += toCamel(strings.ToLower(method))
+	}
+	envelope := object{"error": object{"type": "string"}}
+	if endpoint.Result != nil {
+		envelope["response"] = openAPISchema(endpoint.Result.Schema)
+	}
+	success := object{"description": http.StatusText(endpoint.Status)}
+	if endpoint.ReturnsMeta || endpoint.Status != http.StatusNoContent {
+		success["content"] = object{"application/json": object{"schema": object{
+			"type":       "object",
+			"properties": envelope,
+		}}}
+	}
+	if endpoint.Headers != nil {
+		headers := object{}
+		for _, header := range endpoint.Headers {
+			headers[header.Name] = object{"schema": object{"type": "string", "enum": []string{header.Value}}}
+		}
+		success["headers"] = headers
+	}
+	op := object{
+		"operationId": operationID,
+		"tags":        []string{endpoint.Service.Name},
+		"summary":     "Calls " + endpoint.Service.Name + "." + endpoint.MethodName,
+		"responses": object{
+			strconv.Itoa(endpoint.Status): success,
+			"default": object{
+				"description": "error",
+				"content": object{"application/json": object{"schema": object{
+					"$ref": "#/components/schemas/ErrorResponse",
+				}}},
+			},
+		},
+	}
+	if endpoint.Auth {
+		op["description"] = "Requires authorization."
+	}
+	if endpoint.Roles != nil {
+		// roles are not a part of OpenAPI, x- keys are extensions
+		op["x-roles"] = endpoint.Roles
+	}
+
+	parameters := []object{}
+	body := object{"type": "object", "properties": object{}}
+	var required []string
+	var fields []*Field
+	if endpoint.Params != nil {
+		fields = endpoint.Params.Fields
+	}
+	for _, field := range fields {
+		switch {
+		case field.Source == "path":
+			parameters = append(parameters, object{
+				"name":     field.ParamName,
+				"in":       "path",
+				"required": true,
+				"schema":   openAPIParamSchema(field),
+			})
+		case hasBody(method):
+			body["properties"].(object)[field.ParamName] = openAPIParamSchema(field)
+			if field.Rules.Required {
+				required = append(required, field.ParamName)
+			}
+		default:
+			parameters = append(parameters, object{
+				"name":     field.ParamName,
+				"in":       "query",
+				"required": field.Rules.Required,
+				"schema":   openAPIParamSchema(field),
+			})
+		}
+	}
+	op["parameters"] = parameters
+	if hasBody(method) {
+		if required != nil {
+			body["required"] = required
+		}
+		op["requestBody"] = object{
+			"content": object{"application/x-www-form-urlencoded": object{"schema": body}},
+		}
+	}
+	return op
+}
+
+// openAPIPath is the url of endpoint with type hints dropped from placeholders.
+func openAPIPath(endpoint *Endpoint) string {
+	path := endpoint.URL
+	for _, param := range endpoint.PathParams {
+		path = strings.Replace(path, param.Placeholder, "{"+param.Name+"}", 1)
+	}
+	return path
+}
+
+func openAPIParamSchema(field *Field) object {
+	res := object{"type": "string"}
+	value := func(s string) any { return s }
+	if field.Kind == "int" {
+		res["type"] = "integer"
+		// values of int rules are checked by the parser
+		value = func(s string) any { return json.Number(s) }
+	}
+
+	if field.Rules.Default != nil {
+		res["default"] = value(*field.Rules.Default)
+	}
+	if field.Rules.Enum != nil {
+		var enum []any
+		for _, item := range field.Rules.Enum {
+			enum = append(enum, value(item))
+		}
+		res["enum"] = enum
+	}
+	minKey, maxKey := "minimum", "maximum"
+	if field.Kind == "string" {
+		minKey, maxKey = "minLength", "maxLength"
+	}
+	if field.Rules.Min != nil {
+		res[minKey] = *field.Rules.Min
+	}
+	if field.Rules.Max != nil {
+		res[maxKey] = *field.Rules.Max
+	}
+	return res
+}
+
+func openAPISchema(schema *Schema) object {
+	if schema == nil {
+		return object{}
+	}
+	if schema.Ref != "" {
+		return object{"$ref": "#/components/schemas/" + schema.Ref}
+	}
+
+	res := object{}
+	if schema.Type != "" {
+		res["type"] = schema.Type
+	}
+	if schema.Nullable {
+		res["nullable"] = true
+	}
+	if schema.Items != nil {
+		res["items"] = openAPISchema(schema.Items)
+	}
+	if schema.Values != nil {
+		res["additionalProperties"] = openAPISchema(schema.Values)
+	}
+	if schema.Type == "object" && schema.Values == nil {
+		properties := object{}
+		var required []string
+		for _, property := range schema.Properties {
+			properties[property.Name] = openAPISchema(property.Schema)
+			if !property.OmitEmpty {
+				required = append(required, property.Name)
+			}
+		}
+		res["properties"] = properties
+		if required != nil {
+			res["required"] = required
+		}
+	}
+	return res
+}
@@ -0,0 +1,605 @@
+package apigen
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	apiPrefix     = "// apigen:api"
+	servicePrefix = "// apigen:service"
+)
+
+// httpMethods are the verbs an annotation may list
+var httpMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead}
+
+// annotation is the json part of an apigen:api comment. Auth, Method and
+// Roles are nil when they are not set, the service settings apply then.
+type annotation struct {
+	Url     string            `json:"url"`
+	Auth    *bool             `json:"auth"`
+	Method  methodList        `json:"method"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Roles   []string          `json:"roles"`
+
+	pathParams []*PathParam
+}
+
+// serviceAnnotation is the json part of an apigen:service comment. Prefix is
+// prepended to the url of every method, Auth, Method and Roles are the
+// defaults of methods which do not set them.
+type serviceAnnotation struct {
+	Prefix     string     `json:"prefix"`
+	Auth       bool       `json:"auth"`
+	Method     methodList `json:"method"`
+	Roles      []string   `json:"roles"`
+	StrictHTTP bool       `json:"strict_http"`
+
+	pos token.Pos
+}
+
+// Load parses and type checks the package at path, a directory or a single
+// file, and builds its model. output is the file generated code goes to, it
+// does not take part in the model. Problems of the input are reported as
+// diagnostics, the error is returned only if the package can not be read.
+//
+// Load is safe for concurrent use. While it type checks, build.Default.Dir
+// points at the package, other code reading it at the same time may see that.
+func Load(path, output string) (*API, *Diagnostics, error) {
+	fset := token.NewFileSet()
+	diag := NewDiagnostics(fset)
+	pkg, err := loadPackage(fset, path, output, diag)
+	if err != nil {
+		return nil, diag, err
+	}
+
+	api := &API{
+		Package: pkg.pkg.Name(),
+		PkgPath: pkg.pkg.Path(),
+		Imports: pkg.imports,
+		Fset:    fset,
+	}
+	api.Services = findAllMethods(pkg, findServiceAnnotations(pkg, diag), diag)
+	api.Params = findAllStructs(pkg, api.Services, diag)
+	api.Schemas = findAllSchemas(pkg, api.Services)
+	checkPathParams(pkg, api.Services, diag)
+	return api, diag, nil
+}
+
+// findAllStructs builds params structs of all endpoints, a struct shared by
+// several endpoints is built once.
+func findAllStructs(pkg *packageInfo, services []*Service, diag *Diagnostics) []*Params {
+	var res []*Params
+	byType := make(map[string]*Params)
+	for _, service := range services {
+		for _, endpoint := range service.Endpoints {
+			paramVar, ok := pkg.paramTypes[endpoint]
+			if !ok {
+				continue
+			}
+			typeName := pkg.typeName(types.Unalias(paramVar.Type()))
+			if params, ok := byType[typeName]; ok {
+				endpoint.Params = params
+				continue
+			}
+
+			named, _ := types.Unalias(paramVar.Type()).(*types.Named)
+			if named == nil {
+				diag.Errorf(paramVar.Pos(), "params type %v must be a named struct", typeName)
+				continue
+			}
+			currStruct, ok := named.Underlying().(*types.Struct)
+			if !ok {
+				diag.Errorf(named.Obj().Pos(), "params type %v must be a struct, got %v", typeName, named.Underlying())
+				continue
+			}
+
+			params := &Params{TypeName: typeName, Name: named.Obj().Name(), Pos: named.Obj().Pos()}
+			if named.Obj().Pkg() != pkg.pkg {
+				params.Name = toCamel(pkg.importName(named.Obj().Pkg())) + params.Name
+			}
+			paramNames := make(map[string]string)
+			for i := 0; i < currStruct.NumFields(); i++ {
+				field := currStruct.Field(i)
+				if field.Embedded() || !field.Exported() && field.Pkg() != pkg.pkg {
+					diag.Errorf(field.Pos(), "field %v.%v: can not be filled from generated code", typeName, field.Name())
+					continue
+				}
+
+				basic, ok := field.Type().Underlying().(*types.Basic)
+				if !ok || basic.Kind() != types.Int && basic.Kind() != types.String {
+					diag.Errorf(field.Pos(), "field %v.%v: unsupported type %v, only int and string are allowed", typeName, field.Name(), pkg.typeName(field.Type()))
+					continue
+				}
+
+				paramField := &Field{
+					Name:     field.Name(),
+					Kind:     basic.Name(),
+					TypeName: pkg.typeName(field.Type()),
+					Tag:      reflect.StructTag(currStruct.Tag(i)).Get("apivalidator"),
+					Pos:      field.Pos(),
+				}
+				checkValidatorTag(typeName+"."+field.Name(), paramField, pkg.tagPos(field), diag)
+				if prev, exists := paramNames[paramField.ParamName]; exists {
+					diag.Errorf(field.Pos(), "field %v.%v: parameter %q is already bound to %v", typeName, field.Name(), paramField.ParamName, prev)
+				}
+				paramNames[paramField.ParamName] = field.Name()
+				params.Fields = append(params.Fields, paramField)
+			}
+			byType[typeName] = params
+			endpoint.Params = params
+			res = append(res, params)
+		}
+	}
+	return res
+}
+
+// methodList is the "method" of an annotation, a single verb or a list of them
+type methodList []string
+
+func (m *methodList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*m = nil
+		if single != "" {
+			*m = methodList{single}
+		}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return &json.UnmarshalTypeError{Value: string(data), Type: reflect.TypeOf(list), Struct: "annotation", Field: "method"}
+	}
+	if list == nil {
+		list = []string{}
+	}
+	*m = list
+	return nil
+}
+
+// annotationText returns the json part of the comment starting with prefix
+// and its position.
+func annotationText(comment *ast.Comment, prefix string) (string, token.Pos) {
+	return comment.Text[len(prefix):], comment.Pos() + token.Pos(len(prefix))
+}
+
+// decodeAnnotation decodes text, the json part of an annotation of the given
+// kind starting at start, into v, a pointer to a struct whose json tags are
+// the known keys. Errors are reported at the exact position inside the
+// comment when json tells it. Unknown keys do not stop decoding, decoded
+// tells whether v is filled and ok whether there were no errors at all.
+func decodeAnnotation(text string, start token.Pos, kind string, v any, diag *Diagnostics) (decoded, ok bool) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &keys); err != nil {
+		pos := start
+		if syntaxErr, ok := err.(*json.SyntaxError); ok && syntaxErr.Offset > 0 {
+			pos += token.Pos(syntaxErr.Offset - 1)
+		}
+		diag.Errorf(pos, "malformed %v annotation: %v", kind, err)
+		return false, false
+	}
+
+	known := make(map[string]bool)
+	vType := reflect.TypeOf(v).Elem()
+	for i := 0; i < vType.NumField(); i++ {
+		if name, _, _ := strings.Cut(vType.Field(i).Tag.Get("json"), ","); name != "" {
+			known[name] = true
+		}
+	}
+	ok = true
+	for key := range keys {
+		if !known[key] {
+			diag.Errorf(start+token.Pos(strings.Index(text, strconv.Quote(key))), "unknown %v key %q", kind, key)
+			ok = false
+		}
+	}
+
+	if err := json.Unmarshal([]byte(text), v); err != nil {
+		pos := start
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			pos += token.Pos(strings.Index(text, strconv.Quote(typeErr.Field)))
+		}
+		diag.Errorf(pos, "malformed %v annotation: %v", kind, err)
+		return false, false
+	}
+	return true, ok
+}
+
+// parseAnnotation decodes and checks the json part of an apigen:api comment.
+func parseAnnotation(comment *ast.Comment, diag *Diagnostics) (annotation, bool) {
+	var apiGen annotation
+	text, start := annotationText(comment, apiPrefix)
+	decoded, ok := decodeAnnotation(text, start, "apigen:api", &apiGen, diag)
+	if !decoded {
+		return apiGen, false
+	}
+	if apiGen.Url == "" {
+		diag.Errorf(start, "apigen:api annotation has no url")
+		ok = false
+	}
+	pathParams, err := parsePath(apiGen.Url)
+	if err != nil {
+		pathErr := err.(*pathError)
+		urlStart := start + token.Pos(strings.Index(text, strconv.Quote(apiGen.Url))+1)
+		diag.Errorf(urlStart+token.Pos(pathErr.Offset), "bad url %v: %v", apiGen.Url, pathErr.Msg)
+		ok = false
+	}
+	apiGen.pathParams = pathParams
+	if !checkMethods(text, start, apiGen.Method, diag) {
+		ok = false
+	}
+	if apiGen.Status != 0 && (apiGen.Status < 200 || apiGen.Status > 299) {
+		diag.Errorf(start+token.Pos(strings.Index(text, `"status"`)), "status %d is not a success status, expected 2xx", apiGen.Status)
+		ok = false
+	}
+	if !checkRoles(text, start, apiGen.Roles, diag) {
+		ok = false
+	}
+	if apiGen.Auth != nil && !*apiGen.Auth && len(apiGen.Roles) > 0 {
+		diag.Errorf(start+token.Pos(strings.Index(text, `"roles"`)), "roles require auth, but auth is false")
+		ok = false
+	}
+	canonical := make(map[string]string)
+	for _, name := range sortedKeys(apiGen.Headers) {
+		pos := start + token.Pos(strings.Index(text, strconv.Quote(name)))
+		switch prev, exists := canonical[http.CanonicalHeaderKey(name)]; {
+		case !isToken(name):
+			diag.Errorf(pos, "bad header name %q", name)
+			ok = false
+		case strings.ContainsAny(apiGen.Headers[name], "\r\n"):
+			diag.Errorf(pos, "value of header %v has a line break", name)
+			ok = false
+		case exists:
+			diag.Errorf(pos, "header %v is listed twice, as %v and %v", http.CanonicalHeaderKey(name), prev, name)
+			ok = false
+		}
+		canonical[http.CanonicalHeaderKey(name)] = name
+	}
+	return apiGen, ok
+}
+
+// isToken tells whether s can be a header name.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		alnum := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+		if !alnum && !strings.ContainsRune("!#$%&'*+-.^_`|~", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkMethods reports problems of the method list decoded from text, the
+// json part of an annotation starting at start.
+func checkMethods(text string, start token.Pos, methods methodList, diag *Diagnostics) bool {
+	ok := true
+	if methods != nil && len(methods) == 0 {
+		diag.Errorf(start+token.Pos(strings.Index(text, `"method"`)), "method list is empty")
+		ok = false
+	}
+	for i, method := range methods {
+		pos := start + token.Pos(strings.Index(text, strconv.Quote(method)))
+		switch {
+		case !slices.Contains(httpMethods, method):
+			diag.Errorf(pos, "unsupported method %q, expected one of %v", method, strings.Join(httpMethods, ", "))
+			ok = false
+		case slices.Contains(methods[:i], method):
+			diag.Errorf(start+token.Pos(strings.LastIndex(text, strconv.Quote(method))), "method %q is listed twice", method)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// checkRoles reports empty and repeated roles decoded from text, the json
+// part of an annotation starting at start.
+func checkRoles(text string, start token.Pos, roles []string, diag *Diagnostics) bool {
+	ok := true
+	for i, role := range roles {
+		switch {
+		case role == "":
+			diag.Errorf(start+token.Pos(strings.Index(text, `""`)), "role is empty")
+			ok = false
+		case slices.Contains(roles[:i], role):
+			diag.Errorf(start+token.Pos(strings.LastIndex(text, strconv.Quote(role))), "role %q is listed twice", role)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// checkPrefix reports problems of the prefix of a service annotation.
+func checkPrefix(text string, start token.Pos, prefix string, diag *Diagnostics) bool {
+	var msg string
+	switch {
+	case prefix == "":
+		return true
+	case !strings.HasPrefix(prefix, "/"):
+		msg = "it must start with /"
+	case strings.HasSuffix(prefix, "/"):
+		msg = "it must not end with /"
+	case strings.ContainsAny(prefix, "{}"):
+		msg = "it can not have placeholders"
+	default:
+		return true
+	}
+	diag.Errorf(start+token.Pos(strings.Index(text, strconv.Quote(prefix))+1), "bad prefix %v: %v", prefix, msg)
+	return false
+}
+
+// findServiceAnnotations returns apigen:service annotations of the package
+// types by type name.
+func findServiceAnnotations(pkg *packageInfo, diag *Diagnostics) map[string]*serviceAnnotation {
+	res := make(map[string]*serviceAnnotation)
+	for _, tree := range pkg.files {
+		for _, decl := range tree.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				docs := []*ast.CommentGroup{typeSpec.Doc}
+				if len(genDecl.Specs) == 1 {
+					// the comment of "type T struct{}" belongs to the declaration
+					docs = append(docs, genDecl.Doc)
+				}
+				for _, doc := range docs {
+					if doc == nil {
+						continue
+					}
+					for _, comment := range doc.List {
+						if !strings.HasPrefix(comment.Text, servicePrefix) {
+							continue
+						}
+						if _, exists := res[typeSpec.Name.Name]; exists {
+							diag.Errorf(comment.Pos(), "type %v has several apigen:service annotations", typeSpec.Name.Name)
+							continue
+						}
+						service := &serviceAnnotation{pos: comment.Pos()}
+						text, start := annotationText(comment, servicePrefix)
+						if decoded, _ := decodeAnnotation(text, start, "apigen:service", service, diag); !decoded {
+							continue
+						}
+						if !checkPrefix(text, start, service.Prefix, diag) {
+							service.Prefix = ""
+						}
+						if !checkMethods(text, start, service.Method, diag) {
+							service.Method = nil
+						}
+						if !checkRoles(text, start, service.Roles, diag) {
+							service.Roles = nil
+						}
+						res[typeSpec.Name.Name] = service
+					}
+				}
+			}
+		}
+	}
+	return res
+}
+
+// findAllMethods returns types with apigen:api methods, types and their methods
+// are ordered by source position so that the output does not change between runs.
+func findAllMethods(pkg *packageInfo, serviceAnnotations map[string]*serviceAnnotation, diag *Diagnostics) []*Service {
+	var res []*Service
+	index := make(map[string]*Service)
+	urls := make(map[string]map[string]token.Pos)
+	for _, tree := range pkg.files {
+		for _, decl := range tree.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok || funcDecl.Recv == nil {
+				continue
+			}
+
+			if funcDecl.Doc == nil {
+				continue
+			}
+
+			var annotated token.Pos
+			for _, comment := range funcDecl.Doc.List {
+				if !strings.HasPrefix(comment.Text, apiPrefix) {
+					continue
+				}
+				// one method is one handler, a second one would redeclare it
+				if annotated.IsValid() {
+					diag.Errorf(comment.Pos(), "method %v is already annotated at %v", funcDecl.Name.Name, pkg.fset.Position(annotated))
+					continue
+				}
+				annotated = comment.Pos()
+				apiGen, ok := parseAnnotation(comment, diag)
+
+				method, _ := pkg.info.Defs[funcDecl.Name].(*types.Func)
+				if method == nil {
+					diag.Errorf(funcDecl.Name.Pos(), "method %v is not type checked", funcDecl.Name.Name)
+					continue
+				}
+				sig := method.Type().(*types.Signature)
+				recv := sig.Recv().Type()
+				if pointer, ok := recv.(*types.Pointer); ok {
+					recv = pointer.Elem()
+				}
+				named, _ := recv.(*types.Named)
+				if named == nil {
+					diag.Errorf(funcDecl.Recv.Pos(), "method %v has invalid receiver", funcDecl.Name.Name)
+					continue
+				}
+				structName := named.Obj().Name()
+
+				shape, valid := pkg.checkSignature(structName+"."+funcDecl.Name.Name, sig, funcDecl.Type, diag)
+				if !ok || !valid {
+					continue
+				}
+
+				// settings of the method win over the ones of the service
+				settings := serviceAnnotations[structName]
+				if settings == nil {
+					settings = &serviceAnnotation{}
+				}
+				endpoint := &Endpoint{
+					MethodName:   funcDecl.Name.Name,
+					URL:          settings.Prefix + apiGen.Url,
+					PathParams:   apiGen.pathParams,
+					HTTPMethods:  apiGen.Method,
+					Auth:         settings.Auth,
+					Status:       http.StatusOK,
+					TakesContext: shape.context,
+					ReturnsMeta:  shape.meta,
+					Pos:          funcDecl.Pos(),
+				}
+				if shape.result != nil {
+					_, pointer := types.Unalias(shape.result).(*types.Pointer)
+					endpoint.Result = &Result{TypeName: pkg.typeName(shape.result), Pointer: pointer}
+				} else {
+					endpoint.Status = http.StatusNoContent
+				}
+				if apiGen.Status != 0 {
+					endpoint.Status = apiGen.Status
+				}
+				if endpoint.Status == http.StatusNoContent && shape.result != nil && !shape.meta {
+					// nothing could change the status to send the result
+					diag.Errorf(comment.Slash+token.Pos(strings.Index(comment.Text, `"status"`)), "method %v.%v returns a result, but status 204 has no body", structName, funcDecl.Name.Name)
+					continue
+				}
+				for _, name := range sortedKeys(apiGen.Headers) {
+					endpoint.Headers = append(endpoint.Headers, &Header{Name: http.CanonicalHeaderKey(name), Value: apiGen.Headers[name]})
+				}
+				endpoint.Roles = settings.Roles
+				if apiGen.Roles != nil {
+					endpoint.Roles = apiGen.Roles
+				}
+				if apiGen.Auth != nil {
+					endpoint.Auth = *apiGen.Auth
+					if !endpoint.Auth {
+						// a public method drops the roles of the service
+						endpoint.Roles = nil
+					}
+				}
+				if len(endpoint.Roles) > 0 {
+					endpoint.Auth = true
+				} else {
+					endpoint.Roles = nil
+				}
+				if endpoint.HTTPMethods == nil {
+					endpoint.HTTPMethods = settings.Method
+				}
+				if endpoint.HTTPMethods == nil {
+					endpoint.HTTPMethods = []string{http.MethodGet, http.MethodPost}
+				}
+
+				if urls[structName] == nil {
+					urls[structName] = make(map[string]token.Pos)
+				}
+				route := routeKey(endpoint.URL, endpoint.PathParams)
+				if prev, exists := urls[structName][route]; exists {
+					diag.Errorf(comment.Pos(), "url %v of %v is already used at %v", endpoint.URL, structName, pkg.fset.Position(prev))
+					continue
+				}
+				urls[structName][route] = comment.Pos()
+				if shape.params != nil {
+					pkg.paramTypes[endpoint] = shape.params
+				}
+				if shape.result != nil {
+					pkg.resultTypes[endpoint] = shape.result
+				}
+
+				service, ok := index[structName]
+				if !ok {
+					service = &Service{
+						Name:               structName,
+						Prefix:             settings.Prefix,
+						StrictHTTP:         settings.StrictHTTP,
+						AuthenticatorField: authenticatorField(named, diag),
+						Pos:                named.Obj().Pos(),
+					}
+					index[structName] = service
+					res = append(res, service)
+				}
+				endpoint.Service = service
+				service.Endpoints = append(service.Endpoints, endpoint)
+			}
+		}
+	}
+
+	for name, settings := range serviceAnnotations {
+		if index[name] == nil {
+			diag.Warnf(settings.pos, "apigen:service annotation of %v has no effect, the type has no apigen:api methods", name)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Pos < res[j].Pos
+	})
+	for _, service := range res {
+		sort.Slice(service.Endpoints, func(i, j int) bool {
+			return service.Endpoints[i].Pos < service.Endpoints[j].Pos
+		})
+	}
+	return res
+}
+
+// runtime packages of the module annotated code may use
+const (
+	metaPkgPath = "codegenhw/api_response"
+	authPkgPath = "codegenhw/api_auth"
+)
+
+// isNamed tells whether t is the type name declared in package pkgPath.
+func isNamed(t types.Type, pkgPath, name string) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	return ok && named.Obj().Name() == name && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == pkgPath
+}
+
+// isMeta tells whether t is *api_response.Meta.
+func isMeta(t types.Type) bool {
+	pointer, ok := types.Unalias(t).(*types.Pointer)
+	return ok && isNamed(pointer.Elem(), metaPkgPath, "Meta")
+}
+
+// authenticatorField returns the name of the api_auth.Authenticator field of
+// the service type, or an empty string when it has none.
+func authenticatorField(service *types.Named, diag *Diagnostics) string {
+	fields, ok := service.Underlying().(*types.Struct)
+	if !ok {
+		return ""
+	}
+	var res string
+	for i := 0; i < fields.NumFields(); i++ {
+		field := fields.Field(i)
+		if !isNamed(field.Type(), authPkgPath, "Authenticator") {
+			continue
+		}
+		if res != "" {
+			diag.Errorf(field.Pos(), "field %v.%v: %v has an api_auth.Authenticator in %v already", service.Obj().Name(), field.Name(), service.Obj().Name(), res)
+			continue
+		}
+		res = field.Name()
+	}
+	return res
+}
+
+func toCamel(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '_' || r == ' ' || r == '-'
+	})
+
+	var res []string
+	for _, word := range words {
+		word = strings.Replace(word, string(word[0]), strings.ToUpper(string(word[0])), 1)
+		res = append(res, word)
+	}
+
+	return strings.Join(res, "")
+}
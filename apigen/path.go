@@ -0,0 +1,110 @@
+package apigen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pathError is a problem of an url at byte offset inside it
+type pathError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *pathError) Error() string {
+	return e.Msg
+}
+
+// parsePath returns the placeholders of an endpoint url. A placeholder is a
+// whole segment, "{name}" or "{name:kind}" with kind int or string.
+func parsePath(url string) ([]*PathParam, error) {
+	var res []*PathParam
+	offset := 0
+	for _, segment := range strings.Split(url, "/") {
+		segmentOffset := offset
+		offset += len(segment) + 1
+		if !strings.ContainsAny(segment, "{}") {
+			continue
+		}
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			return nil, &pathError{segmentOffset, fmt.Sprintf("placeholder %q must be a whole path segment", segment)}
+		}
+
+		name, kind, hasKind := strings.Cut(segment[1:len(segment)-1], ":")
+		if !isIdent(name) {
+			return nil, &pathError{segmentOffset, fmt.Sprintf("bad placeholder name %q", name)}
+		}
+		switch {
+		case !hasKind:
+			kind = "string"
+		case kind != "int" && kind != "string":
+			return nil, &pathError{segmentOffset, fmt.Sprintf("unknown placeholder type %q, expected int or string", kind)}
+		}
+		for _, prev := range res {
+			if prev.Name == name {
+				return nil, &pathError{segmentOffset, fmt.Sprintf("placeholder {%v} is used twice", name)}
+			}
+		}
+		res = append(res, &PathParam{Name: name, Kind: kind, Placeholder: segment})
+	}
+	return res, nil
+}
+
+func isIdent(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		letter := r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		if !letter && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// routeKey is the url with placeholders made anonymous, urls with equal keys
+// match the same requests.
+func routeKey(url string, params []*PathParam) string {
+	for _, param := range params {
+		url = strings.Replace(url, param.Placeholder, "{"+param.Kind+"}", 1)
+	}
+	return url
+}
+
+// checkPathParams matches url placeholders against params fields with
+// source=path: every placeholder needs such a field and every such field
+// needs a placeholder in each endpoint using the struct.
+func checkPathParams(pkg *packageInfo, services []*Service, diag *Diagnostics) {
+	for _, service := range services {
+		for _, endpoint := range service.Endpoints {
+			if _, ok := pkg.paramTypes[endpoint]; ok && endpoint.Params == nil {
+				// the params struct is broken and reported already
+				continue
+			}
+			fields := make(map[string]*Field)
+			if endpoint.Params != nil {
+				for _, field := range endpoint.Params.Fields {
+					if field.Source == "path" {
+						fields[field.ParamName] = field
+					}
+				}
+			}
+
+			for _, param := range endpoint.PathParams {
+				field, ok := fields[param.Name]
+				if !ok {
+					diag.Errorf(endpoint.Pos, "url placeholder %v of %v.%v has no params field with source=path", param.Placeholder, service.Name, endpoint.MethodName)
+					continue
+				}
+				if param.Kind == "int" && field.Kind != "int" {
+					diag.Errorf(field.Pos, "field %v.%v: placeholder %v needs an int field, got %v", endpoint.Params.TypeName, field.Name, param.Placeholder, field.TypeName)
+				}
+				delete(fields, param.Name)
+			}
+			for _, field := range fields {
+				diag.Errorf(field.Pos, "field %v.%v: source=path, but url %v of %v.%v has no {%v}", endpoint.Params.TypeName, field.Name, endpoint.URL, service.Name, endpoint.MethodName, field.ParamName)
+			}
+		}
+	}
+}
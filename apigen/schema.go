@@ -0,0 +1,163 @@
+package apigen
+
+import (
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Schema is the json form of a Go type as far as emitters describing the wire
+// format need it.
+type Schema struct {
+	// Type is "object", "array", "string", "integer", "number", "boolean",
+	// empty means any value
+	Type string
+	// Ref names a struct in API.Schemas, the other fields are empty then
+	Ref string
+	// Items are the elements of an array
+	Items *Schema
+	// Properties are the fields of a struct object
+	Properties []*Property
+	// Values are the elements of a map object
+	Values *Schema
+	// Nullable is set for pointers, slices and maps
+	Nullable bool
+}
+
+// Property is a struct field as encoding/json writes it.
+type Property struct {
+	Name      string
+	Schema    *Schema
+	OmitEmpty bool
+}
+
+// schemaOf builds the schema of t, named structs are put into schemas once
+// and referenced by their name.
+func (p *packageInfo) schemaOf(t types.Type, schemas map[string]*Schema) *Schema {
+	t = types.Unalias(t)
+	switch u := t.(type) {
+	case *types.Pointer:
+		res := *p.schemaOf(u.Elem(), schemas)
+		res.Nullable = true
+		return &res
+	case *types.Named:
+		if _, ok := u.Underlying().(*types.Struct); !ok {
+			return p.schemaOf(u.Underlying(), schemas)
+		}
+		name := p.schemaName(u)
+		if _, ok := schemas[name]; !ok {
+			// the placeholder stops recursion of self-referencing types
+			schemas[name] = &Schema{Type: "object"}
+			schemas[name] = p.schemaOf(u.Underlying(), schemas)
+		}
+		return &Schema{Ref: name}
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return &Schema{Type: "boolean"}
+		case u.Info()&types.IsInteger != 0:
+			return &Schema{Type: "integer"}
+		case u.Info()&types.IsFloat != 0:
+			return &Schema{Type: "number"}
+		case u.Info()&types.IsString != 0:
+			return &Schema{Type: "string"}
+		}
+	case *types.Slice:
+		if basic, ok := u.Elem().Underlying().(*types.Basic); ok && basic.Kind() == types.Byte {
+			// []byte is written as base64
+			return &Schema{Type: "string", Nullable: true}
+		}
+		return &Schema{Type: "array", Items: p.schemaOf(u.Elem(), schemas), Nullable: true}
+	case *types.Array:
+		return &Schema{Type: "array", Items: p.schemaOf(u.Elem(), schemas)}
+	case *types.Map:
+		return &Schema{Type: "object", Values: p.schemaOf(u.Elem(), schemas), Nullable: true}
+	case *types.Struct:
+		res := &Schema{Type: "object"}
+		for i := 0; i < u.NumFields(); i++ {
+			field := u.Field(i)
+			if !field.Exported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(reflect.StructTag(u.Tag(i)).Get("json"), ",")
+			if name == "-" && opts == "" {
+				continue
+			}
+			if name == "" && field.Embedded() {
+				// fields of embedded structs are promoted
+				if embedded := p.schemaOf(field.Type(), schemas); embedded.Ref != "" {
+					res.Properties = append(res.Properties, schemas[embedded.Ref].Properties...)
+					continue
+				}
+			}
+			if name == "" {
+				name = field.Name()
+			}
+			res.Properties = append(res.Properties, &Property{
+				Name:      name,
+				Schema:    p.schemaOf(field.Type(), schemas),
+				OmitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+			})
+		}
+		return res
+	}
+	return &Schema{}
+}
+
+// schemaName names the schema of a named struct, types of other packages are
+// prefixed with the package and instances of generic types get their type
+// arguments appended, so Page[User] is PageUser.
+func (p *packageInfo) schemaName(t *types.Named) string {
+	name := t.Obj().Name()
+	if t.Obj().Pkg() != nil && t.Obj().Pkg() != p.pkg {
+		name = toCamel(p.importName(t.Obj().Pkg())) + name
+	}
+	for i := 0; i < t.TypeArgs().Len(); i++ {
+		name += p.typeArgName(t.TypeArgs().At(i))
+	}
+	return name
+}
+
+// typeArgName spells a type argument as a part of a schema name.
+func (p *packageInfo) typeArgName(t types.Type) string {
+	switch u := types.Unalias(t).(type) {
+	case *types.Named:
+		return p.schemaName(u)
+	case *types.Basic:
+		return toCamel(u.Name())
+	case *types.Pointer:
+		return p.typeArgName(u.Elem())
+	case *types.Slice:
+		return p.typeArgName(u.Elem()) + "List"
+	case *types.Array:
+		return p.typeArgName(u.Elem()) + "List"
+	case *types.Map:
+		return p.typeArgName(u.Key()) + p.typeArgName(u.Elem()) + "Map"
+	}
+	return "Any"
+}
+
+// findAllSchemas builds schemas of endpoint results, the named structs they
+// use are collected into the returned map.
+func findAllSchemas(pkg *packageInfo, services []*Service) map[string]*Schema {
+	schemas := make(map[string]*Schema)
+	for _, service := range services {
+		for _, endpoint := range service.Endpoints {
+			if endpoint.Result != nil {
+				endpoint.Result.Schema = pkg.schemaOf(pkg.resultTypes[endpoint], schemas)
+			}
+		}
+	}
+	return schemas
+}
+
+// SchemaNames returns the keys of API.Schemas in a stable order.
+func (api *API) SchemaNames() []string {
+	names := make([]string, 0, len(api.Schemas))
+	for name := range api.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,150 @@
+package apigen
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// signature is the shape of an annotated method, any of
+//
+//	func([context.Context,] [Params]) ([Result,] [*api_response.Meta,] error)
+type signature struct {
+	context bool
+	// params is the params struct argument, nil when there is none
+	params *types.Var
+	// result is nil when the method returns no value besides metadata and error
+	result types.Type
+	meta   bool
+}
+
+// checkSignature matches the signature of method name against the supported
+// shapes and reports what does not fit at pos.
+func (p *packageInfo) checkSignature(name string, sig *types.Signature, fn *ast.FuncType, diag *Diagnostics) (signature, bool) {
+	var res signature
+	pos := fn.Pos()
+	qualifier := types.RelativeTo(p.pkg)
+	params, results := sig.Params(), sig.Results()
+
+	switch {
+	case sig.Variadic():
+		diag.Errorf(pos, "method %v: variadic arguments are not supported", name)
+		return res, false
+	case params.Len() > 2:
+		diag.Errorf(pos, "method %v takes %d arguments, expected context.Context and a params struct at most", name, params.Len())
+		return res, false
+	case params.Len() == 2 && !isContext(params.At(0).Type()):
+		if isContext(params.At(1).Type()) {
+			diag.Errorf(pos, "method %v: context.Context must be the first argument", name)
+		} else {
+			diag.Errorf(pos, "method %v takes two arguments, the first one must be context.Context", name)
+		}
+		return res, false
+	}
+	for i := 0; i < params.Len(); i++ {
+		if invalidType(params.At(i).Type()) {
+			diag.Errorf(pos, "method %v: argument type %v is not defined", name, types.ExprString(fieldType(fn.Params, i)))
+			return res, false
+		}
+	}
+	for i := 0; i < params.Len(); i++ {
+		if isContext(params.At(i).Type()) {
+			res.context = true
+		} else {
+			res.params = params.At(i)
+		}
+	}
+
+	switch {
+	case results.Len() == 0 || results.At(results.Len()-1).Type().String() != "error":
+		diag.Errorf(pos, "method %v must return error as the last result", name)
+		return res, false
+	case results.Len() > 3:
+		diag.Errorf(pos, "method %v returns %d results, expected a result, *api_response.Meta and error at most", name, results.Len())
+		return res, false
+	case results.Len() == 3 && !isMeta(results.At(1).Type()):
+		diag.Errorf(pos, "method %v: the second of three results must be *api_response.Meta, got %v", name, types.TypeString(results.At(1).Type(), qualifier))
+		return res, false
+	}
+	for i := 0; i < results.Len(); i++ {
+		if invalidType(results.At(i).Type()) {
+			diag.Errorf(pos, "method %v: result type %v is not defined", name, types.ExprString(fieldType(fn.Results, i)))
+			return res, false
+		}
+	}
+	for i := 0; i < results.Len()-1; i++ {
+		if isMeta(results.At(i).Type()) {
+			res.meta = true
+		} else {
+			res.result = results.At(i).Type()
+		}
+	}
+	if res.result != nil {
+		if bad := unsupportedJSON(res.result); bad != nil {
+			diag.Errorf(pos, "method %v: result type %v can not be encoded as json, it has %v", name, types.TypeString(res.result, qualifier), types.TypeString(bad, qualifier))
+			return res, false
+		}
+	}
+	return res, true
+}
+
+func isContext(t types.Type) bool {
+	return t.String() == "context.Context"
+}
+
+// invalidType tells whether t failed type checking, e.g. it refers to an
+// undefined type, possibly behind pointers, slices, arrays and maps.
+func invalidType(t types.Type) bool {
+	switch u := t.(type) {
+	case *types.Basic:
+		return u.Kind() == types.Invalid
+	case *types.Named:
+		return invalidType(u.Underlying())
+	case *types.Alias:
+		return invalidType(types.Unalias(u))
+	case *types.Pointer:
+		return invalidType(u.Elem())
+	case *types.Slice:
+		return invalidType(u.Elem())
+	case *types.Array:
+		return invalidType(u.Elem())
+	case *types.Map:
+		return invalidType(u.Key()) || invalidType(u.Elem())
+	}
+	return false
+}
+
+// fieldType returns the type expression of the i-th entry of list, fields
+// like "a, b int" count once per name.
+func fieldType(list *ast.FieldList, i int) ast.Expr {
+	for _, field := range list.List {
+		n := max(len(field.Names), 1)
+		if i < n {
+			return field.Type
+		}
+		i -= n
+	}
+	return nil
+}
+
+// unsupportedJSON returns the part of t encoding/json fails on: a channel,
+// function, complex number or unsafe pointer, possibly behind pointers, slices,
+// arrays and maps. Struct fields are not looked into.
+func unsupportedJSON(t types.Type) types.Type {
+	switch u := t.Underlying().(type) {
+	case *types.Chan, *types.Signature:
+		return t
+	case *types.Basic:
+		if u.Info()&types.IsComplex != 0 || u.Kind() == types.UnsafePointer {
+			return t
+		}
+	case *types.Pointer:
+		return unsupportedJSON(u.Elem())
+	case *types.Slice:
+		return unsupportedJSON(u.Elem())
+	case *types.Array:
+		return unsupportedJSON(u.Elem())
+	case *types.Map:
+		return unsupportedJSON(u.Elem())
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package apigen
+
+import (
+	"codegenhw/api_tag"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// tagPos returns a function mapping an offset inside the apivalidator tag of
+// field to a source position. Fields of imported packages have no syntax at
+// hand, their diagnostics point to the field itself.
+func (p *packageInfo) tagPos(field *types.Var) func(offset int) token.Pos {
+	if p.tagLits == nil {
+		p.tagLits = make(map[token.Pos]*ast.BasicLit)
+		for _, file := range p.files {
+			ast.Inspect(file, func(node ast.Node) bool {
+				if field, ok := node.(*ast.Field); ok && field.Tag != nil {
+					for _, name := range field.Names {
+						p.tagLits[name.Pos()] = field.Tag
+					}
+				}
+				return true
+			})
+		}
+	}
+
+	lit, ok := p.tagLits[field.Pos()]
+	start := -1
+	if ok {
+		const key = `apivalidator:"`
+		if idx := strings.Index(lit.Value, key); idx >= 0 {
+			start = idx + len(key)
+		}
+	}
+	return func(offset int) token.Pos {
+		if start < 0 {
+			return field.Pos()
+		}
+		return lit.Pos() + token.Pos(start+offset)
+	}
+}
+
+// checkValidatorTag reports syntax errors, unknown tags, arguments which do not
+// fit the field type and contradictory rules. It fills the request parameter
+// name of the field and its rules.
+func checkValidatorTag(name string, field *Field, pos func(offset int) token.Pos, diag *Diagnostics) {
+	field.ParamName = strings.ToLower(field.Name)
+	field.Source = "query"
+	parsed, err := api_tag.Parse(field.Tag)
+	if err != nil {
+		syntaxErr := err.(*api_tag.SyntaxError)
+		diag.Errorf(pos(syntaxErr.Offset), "field %v: malformed apivalidator tag: %v", name, syntaxErr.Msg)
+		return
+	}
+
+	rules := make(map[string]api_tag.Rule)
+	for _, rule := range parsed {
+		takesValue, known := api_tag.Known[rule.Name]
+		switch {
+		case !known:
+			diag.Errorf(pos(rule.Offset), "field %v: unknown apivalidator tag %q", name, rule.Name)
+			continue
+		case takesValue && !rule.HasValue:
+			diag.Errorf(pos(rule.Offset), "field %v: apivalidator tag %q needs a value", name, rule.Name)
+			continue
+		case !takesValue && rule.HasValue:
+			diag.Errorf(pos(rule.Offset), "field %v: apivalidator tag %q takes no value", name, rule.Name)
+			continue
+		}
+		if _, exists := rules[rule.Name]; exists {
+			diag.Errorf(pos(rule.Offset), "field %v: duplicate apivalidator tag %q", name, rule.Name)
+			continue
+		}
+		rules[rule.Name] = rule
+	}
+
+	atoi := func(ruleName string) *int {
+		rule, ok := rules[ruleName]
+		if !ok {
+			return nil
+		}
+		value, err := strconv.Atoi(rule.Value)
+		if err != nil {
+			diag.Errorf(pos(rule.ValueOffset), "field %v: %v=%v is not an integer", name, rule.Name, rule.Value)
+			return nil
+		}
+		return &value
+	}
+	field.Rules.Min = atoi("min")
+	field.Rules.Max = atoi("max")
+	if field.Rules.Min != nil && field.Rules.Max != nil && *field.Rules.Min > *field.Rules.Max {
+		diag.Errorf(pos(rules["min"].Offset), "field %v: min=%v is greater than max=%v", name, *field.Rules.Min, *field.Rules.Max)
+	}
+
+	if rule, ok := rules["paramname"]; ok {
+		if rule.Value == "" {
+			diag.Errorf(pos(rule.Offset), "field %v: paramname is empty", name)
+		}
+		field.ParamName = rule.Value
+	}
+
+	if rule, ok := rules["source"]; ok {
+		if rule.Value != "query" && rule.Value != "path" {
+			diag.Errorf(pos(rule.ValueOffset), "field %v: source=%v, expected query or path", name, rule.Value)
+		} else {
+			field.Source = rule.Value
+		}
+	}
+
+	_, field.Rules.Required = rules["required"]
+
+	if rule, ok := rules["enum"]; ok {
+		if field.Kind != "string" {
+			diag.Errorf(pos(rule.Offset), "field %v: enum is supported only for string fields, not %v", name, field.TypeName)
+		}
+		field.Rules.Enum = rule.Values
+		for i, value := range rule.Values {
+			if slices.Contains(rule.Values[:i], value) {
+				diag.Errorf(pos(rule.Offset), "field %v: enum value %q is listed twice", name, value)
+			}
+		}
+	}
+
+	if rule, ok := rules["default"]; ok {
+		if field.Rules.Required {
+			diag.Errorf(pos(rule.Offset), "field %v: default is never used together with required", name)
+		}
+
+		value, size := rule.Value, len(rule.Value)
+		if field.Kind == "int" {
+			number, err := strconv.Atoi(rule.Value)
+			if err != nil {
+				diag.Errorf(pos(rule.ValueOffset), "field %v: default=%v is not an integer", name, rule.Value)
+				return
+			}
+			// 010 or +5 are not the same numbers in Go code and JSON
+			value, size = strconv.Itoa(number), number
+		}
+		if field.Rules.Enum != nil && !slices.Contains(field.Rules.Enum, value) {
+			diag.Errorf(pos(rule.Offset), "field %v: default=%v is not one of enum values", name, rule.Value)
+		}
+		if field.Rules.Min != nil && size < *field.Rules.Min || field.Rules.Max != nil && size > *field.Rules.Max {
+			diag.Errorf(pos(rule.Offset), "field %v: default=%v does not satisfy min/max", name, rule.Value)
+		}
+		field.Rules.Default = &value
+	}
+}
@@ -0,0 +1,219 @@
+package apigen
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Template sets live in templates/<set>/*.tmpl and are embedded into the
+// generator. Every file of a set {{define}}s one template named after the file
+// and all of them are parsed into one template.Template, the emitter executes
+// the one named "file".
+//
+// A project can replace any template of a set: LoadTemplates with a dir looks
+// for dir/<set>/<name>.tmpl and uses it instead of the embedded file with the
+// same name. Files with new names are added to the set, so overrides may
+// define helper templates of their own.
+//
+// The data passed to "file" is the model itself, *API from ir.go. The http set
+// passes *API to "matchPath" and "principal", *Params to "unpack", *Field to
+// "rules", *Endpoint to "handler", "method", "auth" and "query" and *Service
+// to "authenticator", "serveHTTP" and "mount". The client set passes *Service
+// to "client", *Endpoint to "call" and *Field to "value", the docs set
+// *Service to "service", *Endpoint to "endpoint", *Field to "rules" and
+// *Schema to "object". Endpoint.Service leads back to the service. Besides
+// the text/template builtins templates may call
+//
+//	apiError status msg  Go expression of ApiError{status, msg}
+//	badRequest msg       apiError with status 400
+//	zero kind            zero value literal of "int" or "string"
+//	literal kind value   value as an int or a quoted string literal
+//	enumCases kind list  values joined for a case clause
+//	deref ptr            value of *int or *string rule, e.g. .Rules.Min
+//	methodConst verb     net/http constant of the verb, e.g. http.MethodGet
+//	schemaType schema    short description of a *Schema for docs
+//	quote s              strconv.Quote
+//	join list sep        strings.Join
+//
+// In Go sets the output of "file" goes without package clause and imports,
+// they are added by the emitter from identifiers used, and is run through
+// gofmt.
+
+//go:embed templates
+var builtinTemplates embed.FS
+
+var templateFuncs = template.FuncMap{
+	"apiError":    apiError,
+	"badRequest":  badRequest,
+	"zero":        zeroValue,
+	"literal":     literal,
+	"enumCases":   enumCases,
+	"deref":       deref,
+	"methodConst": methodConst,
+	"schemaType":  schemaType,
+	"quote":       strconv.Quote,
+	"join":        strings.Join,
+}
+
+// LoadTemplates parses the template set named set, files of dir/<set> take
+// the place of embedded ones. An empty dir means the embedded set as is.
+func LoadTemplates(set, dir string) (*template.Template, error) {
+	files, err := templateFiles(set, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	root := template.New(set).Funcs(templateFuncs)
+	for _, name := range sortedKeys(files) {
+		if _, err := root.New(name).Parse(string(files[name])); err != nil {
+			return nil, err
+		}
+	}
+	if root.Lookup("file") == nil {
+		return nil, fmt.Errorf("template set %q does not define \"file\"", set)
+	}
+	return root, nil
+}
+
+// templateFiles returns the contents of the set by file name with the
+// overrides from dir applied.
+func templateFiles(set, dir string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	builtin, err := fs.Glob(builtinTemplates, path.Join("templates", set, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	if len(builtin) == 0 {
+		return nil, fmt.Errorf("unknown template set %q", set)
+	}
+	for _, name := range builtin {
+		if files[path.Base(name)], err = builtinTemplates.ReadFile(name); err != nil {
+			return nil, err
+		}
+	}
+
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+		overrides, err := filepath.Glob(filepath.Join(dir, set, "*.tmpl"))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range overrides {
+			if files[filepath.Base(name)], err = os.ReadFile(name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// execute writes the "file" template of the set.
+func execute(w io.Writer, set string, opts Options, api *API) error {
+	tpl, err := LoadTemplates(set, opts.TemplateDir)
+	if err != nil {
+		return err
+	}
+	if err := tpl.ExecuteTemplate(w, "file", api); err != nil {
+		return fmt.Errorf("template: %v", err)
+	}
+	return nil
+}
+
+// executeGo writes the "file" template of a Go set as a formatted file.
+func executeGo(w io.Writer, set string, opts Options, api *API) error {
+	var out bytes.Buffer
+	if err := execute(&out, set, opts, api); err != nil {
+		return err
+	}
+	src, err := formatFile(api.Package, out.Bytes(), api.Imports, opts.InputHash)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func apiError(status int, message string) string {
+	return fmt.Sprintf("ApiError{HTTPStatus: %d, Err: fmt.Errorf(%q)}", status, message)
+}
+
+func badRequest(message string) string {
+	return apiError(400, message)
+}
+
+func zeroValue(kind string) string {
+	if kind == "string" {
+		return `""`
+	}
+	return "0"
+}
+
+func literal(kind, value string) string {
+	if kind == "string" {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+func enumCases(kind string, enum []string) string {
+	var cases []string
+	for _, value := range enum {
+		cases = append(cases, literal(kind, value))
+	}
+	return strings.Join(cases, ", ")
+}
+
+func deref(value any) (any, error) {
+	switch value := value.(type) {
+	case *int:
+		if value != nil {
+			return *value, nil
+		}
+	case *string:
+		if value != nil {
+			return *value, nil
+		}
+	default:
+		return nil, fmt.Errorf("deref of %T", value)
+	}
+	return nil, fmt.Errorf("deref of nil rule")
+}
+
+func methodConst(method string) string {
+	return "http.Method" + toCamel(strings.ToLower(method))
+}
+
+func schemaType(schema *Schema) string {
+	switch {
+	case schema == nil || schema.Ref == "" && schema.Type == "":
+		return "any"
+	case schema.Ref != "":
+		return fmt.Sprintf("[%v](#%v)", schema.Ref, strings.ToLower(schema.Ref))
+	case schema.Items != nil:
+		return "array of " + schemaType(schema.Items)
+	case schema.Values != nil:
+		return "map of " + schemaType(schema.Values)
+	}
+	return schema.Type
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"codegenhw/api_auth"
+	"context"
+)
+
+// Api checks requests with Auth, api_auth.Default when it is nil.
+type Api struct {
+	Auth api_auth.Authenticator
+}
+
+// DefaultApi has no authenticator of its own.
+type DefaultApi struct{}
+
+type Params struct {
+	Name string `apivalidator:"required"`
+}
+
+type User struct {
+	Name string `json:"name"`
+}
+
+// apigen:api {"url": "/user", "auth": true}
+func (srv *Api) Get(ctx context.Context, in Params) (*User, error) {
+	return &User{Name: in.Name}, nil
+}
+
+// apigen:api {"url": "/user", "auth": true}
+func (srv *DefaultApi) Get(ctx context.Context, in Params) (*User, error) {
+	return &User{Name: in.Name}, nil
+}
+
+// apigen:service {"prefix": "/admin", "roles": ["admin"]}
+type AdminApi struct{}
+
+// apigen:api {"url": "/ban"}
+func (srv *AdminApi) Ban(ctx context.Context, in Params) error {
+	return nil
+}
+
+// apigen:api {"url": "/report", "roles": ["admin", "moderator"]}
+func (srv *AdminApi) Report(ctx context.Context, in Params) error {
+	return nil
+}
+
+// apigen:api {"url": "/status", "auth": false}
+func (srv *AdminApi) Status(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,30 @@
+package basic
+
+import "context"
+
+type Api struct{}
+
+type ProfileParams struct {
+	Login string `apivalidator:"required"`
+}
+
+type CreateParams struct {
+	Login  string `apivalidator:"required,min=10"`
+	Name   string `apivalidator:"paramname=full_name"`
+	Status string `apivalidator:"enum=user|moderator|admin,default=user"`
+	Age    int    `apivalidator:"min=0,max=128"`
+}
+
+type User struct {
+	Login string `json:"login"`
+}
+
+// apigen:api {"url": "/user/profile", "auth": false}
+func (srv *Api) Profile(ctx context.Context, in ProfileParams) (*User, error) {
+	return &User{Login: in.Login}, nil
+}
+
+// apigen:api {"url": "/user/create", "auth": true, "method": "POST"}
+func (srv *Api) Create(ctx context.Context, in CreateParams) (*User, error) {
+	return &User{Login: in.Login}, nil
+}
@@ -0,0 +1,9 @@
+package models
+
+type Params struct {
+	Name string `apivalidator:"required"`
+}
+
+type User struct {
+	Name string `json:"name"`
+}
@@ -0,0 +1,20 @@
+package imports
+
+import (
+	amodels "codegenhw/apigen/testdata/imports/a/models"
+	bmodels "codegenhw/apigen/testdata/imports/b/models"
+	"codegenhw/apigen/testdata/imports/url"
+	"context"
+)
+
+type Api struct{}
+
+// apigen:api {"url": "/user"}
+func (srv *Api) Get(ctx context.Context, in amodels.Params) (*amodels.User, error) {
+	return &amodels.User{Name: in.Name}, nil
+}
+
+// apigen:api {"url": "/link"}
+func (srv *Api) Link(ctx context.Context, in bmodels.Params) (url.Link, error) {
+	return url.Link{}, nil
+}
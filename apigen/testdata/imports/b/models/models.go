@@ -0,0 +1,5 @@
+package models
+
+type Params struct {
+	ID int `apivalidator:"min=1"`
+}
@@ -0,0 +1,6 @@
+// Package url shares its name with net/url the generated code uses.
+package url
+
+type Link struct {
+	Href string `json:"href"`
+}
@@ -0,0 +1,77 @@
+package invalid
+
+import "context"
+
+type Api struct{}
+
+type Params struct {
+	A string `apivalidator:"requird"`
+	B int    `apivalidator:"min=10,max=1"`
+	C int    `apivalidator:"enum=a|b"`
+	D string `apivalidator:"enum=a|b,default=c"`
+	E []int
+}
+
+// apigen:api {"url": "/a", "auth": tru}
+func (srv *Api) A(ctx context.Context, in Params) (*Params, error) { return nil, nil }
+
+// apigen:api {"url": "/b", "methd": "POST"}
+func (srv *Api) B(ctx context.Context, in Params) (*Params, error) { return nil, nil }
+
+// apigen:api {"url": "/c"}
+func (srv *Api) C(in Params, ctx context.Context) (*Params, error) { return nil, nil }
+
+// apigen:api {"url": "/d"}
+func (srv *Api) D(ctx context.Context, in Params) (*Params, error) { return nil, nil }
+
+type PathParams struct {
+	ID   string `apivalidator:"source=path"`
+	Page int    `apivalidator:"source=body"`
+	Tab  string `apivalidator:"source=path"`
+}
+
+// apigen:api {"url": "/e/{id:int}/{name}"}
+func (srv *Api) E(ctx context.Context, in PathParams) (*Params, error) { return nil, nil }
+
+// apigen:api {"url": "/f/x{id}"}
+func (srv *Api) F(ctx context.Context, in PathParams) (*Params, error) { return nil, nil }
+
+// apigen:api {"url": "/g", "method": ["GET", "TRACE", "GET"]}
+func (srv *Api) G(ctx context.Context, in Params) (*Params, error) { return nil, nil }
+
+// apigen:api {"url": "/h", "method": 1}
+func (srv *Api) H(ctx context.Context, in Params) (*Params, error) { return nil, nil }
+
+// apigen:service {"strict": true}
+type I struct{}
+
+// apigen:service {"prefix": "/j/", "method": []}
+type J struct{}
+
+// apigen:api {"url": "/j"}
+func (srv *J) J(ctx context.Context, in Params) (*Params, error) { return nil, nil }
+
+// apigen:api {"url": "/k", "status": 302, "headers": {"X Bad": "1", "X-Dup": "a", "x-dup": "b"}}
+func (srv *Api) K(ctx context.Context, in Params) (*Params, error) { return nil, nil }
+
+// apigen:api {"url": "/l"}
+func (srv *Api) L(ctx context.Context) (map[string]chan int, error) { return nil, nil }
+
+// apigen:api {"url": "/m"}
+func (srv *Api) M(ctx context.Context, in Params) *Params { return nil }
+
+// apigen:api {"url": "/n"}
+func (srv *Api) N(ctx context.Context, in Params) (*Params, *Params, error) { return nil, nil, nil }
+
+// apigen:api {"url": "/q", "status": 204}
+func (srv *Api) Q(ctx context.Context) (*Params, error) { return nil, nil }
+
+// apigen:api {"url": "/r"}
+func (srv *Api) R(ctx context.Context, in Missing) (*Params, error) { return nil, nil }
+
+// apigen:api {"url": "/s"}
+func (srv *Api) S(ctx context.Context) ([]*Unknown, error) { return nil, nil }
+
+// apigen:api {"url": "/t"}
+// apigen:api {"url": "/t2"}
+func (srv *Api) T(ctx context.Context) (*Params, error) { return nil, nil }
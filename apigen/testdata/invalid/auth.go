@@ -0,0 +1,17 @@
+package invalid
+
+import (
+	"codegenhw/api_auth"
+	"context"
+)
+
+type O struct {
+	A api_auth.Authenticator
+	B api_auth.Authenticator
+}
+
+// apigen:api {"url": "/o", "auth": true}
+func (srv *O) O(ctx context.Context) error { return nil }
+
+// apigen:api {"url": "/p", "auth": false, "roles": ["a", "", "a"]}
+func (srv *O) P(ctx context.Context) error { return nil }
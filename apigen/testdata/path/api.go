@@ -0,0 +1,30 @@
+package path
+
+import "context"
+
+type Api struct{}
+
+type ProfileParams struct {
+	ID     int    `apivalidator:"source=path,min=1"`
+	Tab    string `apivalidator:"source=path,enum=info|posts"`
+	Format string `apivalidator:"default=json"`
+}
+
+type User struct {
+	ID  int    `json:"id"`
+	Tab string `json:"tab"`
+}
+
+// apigen:api {"url": "/user/{id:int}/{tab}"}
+func (srv *Api) Profile(ctx context.Context, in ProfileParams) (*User, error) {
+	return &User{ID: in.ID, Tab: in.Tab + "/" + in.Format}, nil
+}
+
+type ListParams struct {
+	Limit int `apivalidator:"default=010"`
+}
+
+// apigen:api {"url": "/user/list"}
+func (srv *Api) List(ctx context.Context, in ListParams) (*User, error) {
+	return &User{ID: in.Limit}, nil
+}
@@ -0,0 +1,41 @@
+package response
+
+import (
+	"codegenhw/api_response"
+	"context"
+	"net/http"
+)
+
+type Api struct{}
+
+type Params struct {
+	Name string `apivalidator:"required"`
+}
+
+type User struct {
+	Name string `json:"name"`
+}
+
+// apigen:api {"url": "/user/create", "method": "POST", "status": 201, "headers": {"cache-control": "no-store"}}
+func (srv *Api) Create(ctx context.Context, in Params) (*User, error) {
+	return &User{Name: in.Name}, nil
+}
+
+// apigen:api {"url": "/user/login", "method": "POST"}
+func (srv *Api) Login(ctx context.Context, in Params) (*User, *api_response.Meta, error) {
+	meta := &api_response.Meta{
+		Header:  http.Header{"Location": {"/user/" + in.Name}},
+		Cookies: []*http.Cookie{{Name: "session", Value: in.Name}},
+	}
+	return &User{Name: in.Name}, meta, nil
+}
+
+// apigen:api {"url": "/user/accept", "method": "POST", "headers": {"X-Queue": "users"}}
+func (srv *Api) Accept(ctx context.Context, in Params) (*User, *api_response.Meta, error) {
+	return &User{Name: in.Name}, &api_response.Meta{Status: http.StatusAccepted}, nil
+}
+
+// apigen:api {"url": "/user/search", "method": "GET"}
+func (srv *Api) Search(ctx context.Context) ([]User, error) {
+	return []User{}, nil
+}
@@ -0,0 +1,46 @@
+package service
+
+import "context"
+
+// StrictApi answers wrong verbs with 405.
+//
+// apigen:service {"strict_http": true}
+type StrictApi struct{}
+
+type LegacyApi struct{}
+
+type Params struct {
+	Name string `apivalidator:"required"`
+}
+
+type User struct {
+	Name string `json:"name"`
+}
+
+// apigen:api {"url": "/user", "method": "GET"}
+func (srv *StrictApi) Get(ctx context.Context, in Params) (*User, error) {
+	return &User{Name: in.Name}, nil
+}
+
+// apigen:api {"url": "/user/create", "method": "POST"}
+func (srv *StrictApi) Create(ctx context.Context, in Params) (*User, error) {
+	return &User{Name: in.Name}, nil
+}
+
+// apigen:api {"url": "/user", "method": "GET"}
+func (srv *LegacyApi) Get(ctx context.Context, in Params) (*User, error) {
+	return &User{Name: in.Name}, nil
+}
+
+// apigen:service {"prefix": "/admin", "auth": true, "method": "POST"}
+type AdminApi struct{}
+
+// apigen:api {"url": "/ban"}
+func (srv *AdminApi) Ban(ctx context.Context, in Params) (*User, error) {
+	return &User{Name: in.Name}, nil
+}
+
+// apigen:api {"url": "/status", "auth": false, "method": "GET"}
+func (srv *AdminApi) Status(ctx context.Context, in Params) (*User, error) {
+	return &User{Name: in.Name}, nil
+}
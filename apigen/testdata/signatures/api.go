@@ -0,0 +1,76 @@
+package signatures
+
+import (
+	"codegenhw/api_response"
+	"context"
+)
+
+type Api struct{}
+
+type Params struct {
+	Name string `apivalidator:"required"`
+}
+
+type User struct {
+	Name string `json:"name"`
+}
+
+// apigen:api {"url": "/me"}
+func (srv *Api) Me(ctx context.Context) (*User, error) {
+	return &User{Name: "me"}, nil
+}
+
+// apigen:api {"url": "/delete", "method": "POST"}
+func (srv *Api) Delete(ctx context.Context, in Params) error {
+	return nil
+}
+
+// apigen:api {"url": "/user"}
+func (srv *Api) Get(in Params) (User, error) {
+	return User{Name: in.Name}, nil
+}
+
+// apigen:api {"url": "/list"}
+func (srv *Api) List() ([]User, error) {
+	return []User{{Name: "a"}}, nil
+}
+
+// apigen:api {"url": "/index"}
+func (srv *Api) Index(ctx context.Context) (map[string]int, *api_response.Meta, error) {
+	return map[string]int{"a": 1}, nil, nil
+}
+
+// apigen:api {"url": "/ping"}
+func (srv *Api) Ping() error {
+	return nil
+}
+
+// apigen:api {"url": "/logout", "method": "POST"}
+func (srv *Api) Logout(ctx context.Context) (*api_response.Meta, error) {
+	return &api_response.Meta{}, nil
+}
+
+// apigen:api {"url": "/refresh", "method": "POST", "status": 204}
+func (srv *Api) Refresh(ctx context.Context) (*User, *api_response.Meta, error) {
+	return &User{Name: "me"}, &api_response.Meta{Status: 200}, nil
+}
+
+// apigen:api {"url": "/touch", "method": "POST", "status": 204}
+func (srv *Api) Touch(in Params) error {
+	return nil
+}
+
+type Page[T any] struct {
+	Items []T `json:"items"`
+	Total int `json:"total"`
+}
+
+// apigen:api {"url": "/users"}
+func (srv *Api) Users() (*Page[User], error) {
+	return &Page[User]{Items: []User{{Name: "a"}}, Total: 1}, nil
+}
+
+// apigen:api {"url": "/counts"}
+func (srv *Api) Counts() (*Page[int], error) {
+	return &Page[int]{Items: []int{1}, Total: 1}, nil
+}
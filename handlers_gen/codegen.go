@@ -1,273 +1,170 @@
+// handlers_gen generates net/http handlers for methods marked with apigen:api,
+// all the work is done by the apigen package.
 package main
 
 import (
-	. "codegenhw/api_error"
-	"encoding/json"
+	"bytes"
+	"codegenhw/apigen"
+	"flag"
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
-	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
-	"text/template"
+	"time"
 )
 
-type ApiGen struct {
-	MethodName string
-	ParamName  string
-	ReturnName string
-	Url        string `json:"url"`
-	Auth       bool   `json:"auth"`
-	Method     string `json:"method"`
-}
+func main() {
 
-func findAllMethods(tree *ast.File) map[string][]ApiGen {
-	res := make(map[string][]ApiGen)
-	for _, decl := range tree.Decls {
-		funcDecl, ok := decl.(*ast.FuncDecl)
-		if !ok || funcDecl.Recv == nil {
-			continue
+	check := flag.Bool("check", false, "do not write the output, exit with 1 and print a diff if it is out of date")
+	showDiff := flag.Bool("diff", false, "do not write the output, print a diff against the existing one")
+	emit := flag.String("emit", "http", "comma separated `backends` to run, see below")
+	watchMode := flag.Bool("watch", false, "keep running and regenerate when annotated files of the package change")
+	interval := flag.Duration("interval", 500*time.Millisecond, "how often -watch looks for changes")
+	force := flag.Bool("force", false, "regenerate even if the input hash recorded in the output did not change")
+	templates := flag.String("templates", "", "directory with `<set>/*.tmpl` files replacing the embedded templates of the same name")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: handlers_gen [flags] <package dir or file> [output file or - for stdout]")
+		fmt.Fprintln(os.Stderr, "With several backends the output is a directory, each backend writes its own file there.")
+		fmt.Fprintln(os.Stderr, "Without output the files are written next to the package sources.")
+		flag.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "Backends:")
+		for _, backend := range apigen.Backends() {
+			fmt.Fprintf(os.Stderr, "  %-8v %v, %v\n", backend.Name, backend.Description, backend.FileName)
 		}
-
-		if funcDecl.Doc == nil {
-			continue
+	}
+	flag.Parse()
+	if flag.NArg() != 1 && flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	input, output := flag.Arg(0), flag.Arg(1)
+
+	var backends []apigen.Backend
+	for _, name := range strings.Split(*emit, ",") {
+		backend, ok := apigen.LookupBackend(strings.TrimSpace(name))
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown -emit value %q\n", name)
+			os.Exit(2)
 		}
-
-		getTypeName := func(exp ast.Expr) string {
-			var typeName string
-			switch t := exp.(type) {
-			case *ast.Ident:
-				typeName = t.Name
-			case *ast.StarExpr:
-				if ident, ok := t.X.(*ast.Ident); ok {
-					typeName = ident.Name
-				}
-			}
-			return typeName
+		backends = append(backends, backend)
+	}
+	if len(backends) > 1 && output == "-" {
+		fmt.Fprintln(os.Stderr, "output of several backends must be a directory")
+		os.Exit(2)
+	}
+	if output == "" {
+		output = input
+		if info, err := os.Stat(input); err != nil || !info.IsDir() {
+			output = filepath.Dir(input)
 		}
-
-		structName := getTypeName(funcDecl.Recv.List[0].Type)
-		for _, comment := range funcDecl.Doc.List {
-			var apiGen ApiGen
-			if strings.HasPrefix(comment.Text, "// apigen:api") {
-				after, _ := strings.CutPrefix(comment.Text, "// apigen:api")
-
-				err := json.Unmarshal([]byte(after), &apiGen)
-				if err != nil {
-					fmt.Println(err)
-				}
-			}
-			apiGen.MethodName = funcDecl.Name.Name
-			apiGen.ParamName = getTypeName(funcDecl.Type.Params.List[1].Type)
-			apiGen.ReturnName = getTypeName(funcDecl.Type.Results.List[0].Type)
-			methods := res[structName]
-			res[structName] = append(methods, apiGen)
+		if len(backends) == 1 {
+			output = filepath.Join(output, backends[0].FileName)
 		}
 	}
-	return res
-}
-
-func toCamel(s string) string {
-	words := strings.FieldsFunc(s, func(r rune) bool {
-		return r == '_' || r == ' ' || r == '-'
-	})
 
-	var res []string
-	for _, word := range words {
-		word = strings.Replace(word, string(word[0]), strings.ToUpper(string(word[0])), 1)
-		res = append(res, word)
+	opts := apigen.Options{TemplateDir: *templates}
+	if *watchMode {
+		if *check || *showDiff || output == "-" {
+			fmt.Fprintln(os.Stderr, "-watch writes files, it can not be used with -check, -diff or stdout")
+			os.Exit(2)
+		}
+		watch(input, output, *interval, func() {
+			if run(input, output, backends, opts, *force, false, false) == 0 {
+				fmt.Println(time.Now().Format(time.TimeOnly), "regenerated")
+			}
+		})
 	}
-
-	return strings.Join(res, "")
-}
-
-var decodeStr = `err := validator.Decode(&params, query)
-	if err != nil {
-		WriteError(w, err)
-		return
-	}`
-
-var validatorStr = `
-	bodyBytes, _ := io.ReadAll(r.Body)
-	defer r.Body.Close()
-	query, _ = url.ParseQuery(string(bodyBytes))
-	` + decodeStr
-
-type paramsTpl struct {
-	ParamsName string
-	StructName string
-	MethodName string
-}
-
-var paramsTemplate = template.Must(template.New("paramsTemplate").Funcs(template.FuncMap{
-	//"camel": toCamel,
-}).Parse(`
-	var params {{.ParamsName}}
-	var response {{.StructName}}{{.MethodName}}Response
-`))
-
-type errorTpl struct {
-	Condition string
-	Error     error
-}
-
-func formatError(err error) string {
-	if apiErr, ok := err.(ApiError); ok {
-		return fmt.Sprintf("ApiError{HTTPStatus: %d, Err: fmt.Errorf(\"%s\")}", apiErr.HTTPStatus, apiErr.Err.Error())
+	if code := run(input, output, backends, opts, *force, *check, *showDiff); code != 0 {
+		os.Exit(code)
 	}
-	return "err"
-}
-
-var errorTemplate = template.Must(template.New("errorTemplate").Funcs(template.FuncMap{
-	"formatError": formatError,
-}).Parse(`	if {{.Condition}} {
-		WriteError(w, {{.Error | formatError}})
-		return
-}
-`))
-
-type responseTpl struct {
-	StructName   string
-	MethodName   string
-	UserTypeName string
-}
-
-var responseTemplate = template.Must(template.New("responseTemplate").Parse("type {{.StructName}}{{.MethodName}}Response struct {\n" +
-	"    Error string `json:\"error\"`\n" +
-	"    User  *{{.UserTypeName}}  `json:\"response,omitempty\"`\n" +
-	"}\n"))
-
-func processPostRequest(out *os.File, funcName string) {
-	// ошибки типа bad method и unauthorized должны браться из API?
-	errorTemplate.Execute(out, errorTpl{Condition: "r.Method != http.MethodPost",
-		Error: ApiError{HTTPStatus: http.StatusNotAcceptable, Err: fmt.Errorf("bad method")}})
-
-	fmt.Fprintln(out, "auth, ok := r.Header[\"X-Auth\"]")
-
-	errorTemplate.Execute(out, errorTpl{Condition: "!ok || auth[0] != \"100500\"",
-		Error: ApiError{HTTPStatus: http.StatusForbidden, Err: fmt.Errorf("unauthorized")}})
-	fmt.Fprintln(out, validatorStr)
-}
-
-func processGetRequest(out *os.File, funcName string) {
-	fmt.Fprintf(out, `	if r.Method == http.MethodPost {
-		bodyBytes, _ := io.ReadAll(r.Body)
-		defer r.Body.Close()
-		query, _ = url.ParseQuery(string(bodyBytes))
-	} else if r.Method == http.MethodGet {
-		query = r.URL.Query()
+	if output != "-" && !*check && !*showDiff {
+		fmt.Println("Codegen complited")
 	}
-	`)
-	fmt.Fprintln(out, decodeStr)
 }
 
-func generateHTTPHandlers(methods map[string][]ApiGen, out *os.File) map[string]string {
-
-	res := make(map[string]string)
-	for structName, apis := range methods {
-		for _, api := range apis {
-			responseTemplate.Execute(out, responseTpl{structName, api.MethodName, api.ReturnName})
-			fmt.Fprintf(out, "func (s %v) handle%v (w http.ResponseWriter, r *http.Request) { \n", structName, api.MethodName)
-			paramsTemplate.Execute(out, paramsTpl{api.ParamName, structName, api.MethodName})
-			//apivalidator быть не должен, должен быть метод unpack свой для каждой структуры, его также нужно генерировать.
-			fmt.Fprintf(out, "\tvar validator ApiValidator\n\tvar query url.Values\n")
-			if api.Method == "POST" {
-				processPostRequest(out, api.MethodName)
-			} else {
-				processGetRequest(out, api.MethodName)
-			}
-			fmt.Fprintf(out, "\tuser, err := s.%v(r.Context(), params)\n", toCamel(api.MethodName))
-			errorTemplate.Execute(out, errorTpl{Condition: "err != nil", Error: fmt.Errorf("")})
-			fmt.Fprintln(out, "response.User = user")
-			fmt.Fprintln(out, "data, err := json.Marshal(response)")
-			errorTemplate.Execute(out, errorTpl{Condition: "err != nil",
-				Error: ApiError{HTTPStatus: http.StatusInternalServerError, Err: fmt.Errorf("err")}}) // недоработка вот здесь
-			fmt.Fprintln(out, "w.Write(data)")
-			fmt.Fprintln(out, "\n}")
-			res[api.Url] = fmt.Sprintf("handle%v", api.MethodName)
+// run loads the package and runs the backends, the result is the exit code.
+// Problems are printed to stderr. Outputs whose recorded input hash matches
+// the current one are left alone unless force is set, the package is not even
+// loaded when all of them are up to date.
+func run(input, output string, backends []apigen.Backend, opts apigen.Options, force, check, showDiff bool) int {
+	targets := make(map[string]string)
+	hashes := make(map[string]string)
+	var pending []apigen.Backend
+	for _, backend := range backends {
+		target := output
+		if len(backends) > 1 {
+			target = filepath.Join(output, backend.FileName)
 		}
-	}
-	return res
-}
-
-func generateServeHTTP(methods map[string][]ApiGen, handlers map[string]string, out *os.File) {
-
-	type tpl struct {
-		Url        string
-		MethodName string
-	}
-	var template = template.Must(template.New("paramsTemplate").Parse(`	case "{{.Url}}":
-	s.{{.MethodName}}(w, r)
-`))
-
-	for structName, apis := range methods {
-		structArgumentName := "s"
-
-		fmt.Fprintf(out, "func (%v *%v) ServeHTTP(w http.ResponseWriter, r *http.Request) {\n\n", structArgumentName, structName)
-		fmt.Fprintln(out, "\tw.Header().Set(\"Content-Type\", \"application/json\")")
-		fmt.Fprintln(out, "\tswitch r.URL.Path {")
-		for _, api := range apis {
-			template.Execute(out, tpl{api.Url, handlers[api.Url]})
+		hash, err := apigen.InputHash(input, target, backend, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", backend.Name, err)
+			return 1
 		}
-		fmt.Fprintf(out, "\tdefault:\n\t\t WriteError(w,%v)", formatError(ApiError{HTTPStatus: http.StatusNotFound, Err: fmt.Errorf("unknown method")}))
-		fmt.Fprintln(out, "\t}\n}")
+		if !force && !check && !showDiff && target != "-" && apigen.ReadInputHash(target) == hash {
+			continue
+		}
+		targets[backend.Name], hashes[backend.Name] = target, hash
+		pending = append(pending, backend)
 	}
-}
-
-func main() {
-
-	if len(os.Args) < 3 {
-		fmt.Println("Invalid arguments")
-		return
+	if len(pending) == 0 {
+		return 0
 	}
 
-	fset := token.NewFileSet()
-	tree, err := parser.ParseFile(fset, os.Args[1], nil, parser.ParseComments)
-	//ast.Fprint(os.Stdout, fset, tree, nil)
+	api, diag, err := apigen.Load(input, output)
 	if err != nil {
-		fmt.Println(err)
-		return
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
-
-	out, err := os.Create(os.Args[2])
-	if err != nil {
-		fmt.Println(err)
-		return
+	diag.Print(os.Stderr)
+	if diag.HasErrors() {
+		return 1
 	}
 
-	fmt.Fprintln(out, "package "+tree.Name.Name)
-	fmt.Fprintln(out, "import (")
-	fmt.Fprintln(out, "\t\"encoding/json\"")
-	fmt.Fprintln(out, ". \"codegenhw/api_error\"")
-	fmt.Fprintln(out, "\t\"fmt\"")
-	fmt.Fprintln(out, "\t\"io\"")
-	fmt.Fprintln(out, "\t\"net/http\"")
-	fmt.Fprintln(out, "\t\"net/url\"")
-	fmt.Fprintln(out, ")")
+	stale := false
+	for _, backend := range pending {
+		backendOpts := opts
+		backendOpts.InputHash = hashes[backend.Name]
+		var out bytes.Buffer
+		if err := backend.New(backendOpts).Emit(&out, api); err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", backend.Name, err)
+			return 1
+		}
+		src := out.Bytes()
 
-	//structs := findAllStructs(node)
-	methods := findAllMethods(tree)
+		target := targets[backend.Name]
+		if target == "-" {
+			os.Stdout.Write(src)
+			continue
+		}
 
-	fmt.Fprintf(out, "type ErrorResponse struct {\n \tError string `json:\"error\"` \n}\n")
+		if check || showDiff {
+			current, err := os.ReadFile(target)
+			if err != nil && !os.IsNotExist(err) {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			if unifiedDiff(os.Stdout, target, target+" (generated)", current, src) && check {
+				fmt.Fprintf(os.Stderr, "%v is out of date, regenerate it\n", target)
+				stale = true
+			}
+			continue
+		}
 
-	//writes help method to send error in body
-	fmt.Fprintln(out, `func WriteError(w http.ResponseWriter, err error) {
-	var response ErrorResponse
-	if apiError, ok := err.(ApiError); ok {
-		w.WriteHeader(apiError.HTTPStatus)
-	} else {
-		w.WriteHeader(http.StatusInternalServerError)
+		if current, err := os.ReadFile(target); err == nil && bytes.Equal(current, src) {
+			// keep the modification time for build caches
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := os.WriteFile(target, src, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
 	}
-	response.Error = err.Error()
-	data, _ := json.Marshal(response)
-	w.Write(data)
-}`)
-
-	handlers := generateHTTPHandlers(methods, out)
-	generateServeHTTP(methods, handlers, out)
-
-	fmt.Fprintln(out) // empty string
-
-	fmt.Println("Codegen complited")
+	if stale {
+		return 1
+	}
+	return 0
 }
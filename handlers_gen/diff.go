@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const diffContext = 3
+
+type diffLine struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maxDiffEdits bounds the work of diffLines, the memory it takes grows with
+// the square of the number of edits.
+const maxDiffEdits = 1000
+
+// diffLines is a Myers diff, common prefix and suffix are cut off first since
+// generated files usually differ in a few places only. When the rest takes
+// more than maxDiffEdits edits it is written as removed and added as a whole.
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var res []diffLine
+	for _, line := range a[:prefix] {
+		res = append(res, diffLine{' ', line})
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if mid, ok := myersDiff(midA, midB, maxDiffEdits); ok {
+		res = append(res, mid...)
+	} else {
+		for _, line := range midA {
+			res = append(res, diffLine{'-', line})
+		}
+		for _, line := range midB {
+			res = append(res, diffLine{'+', line})
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		res = append(res, diffLine{' ', line})
+	}
+	return res
+}
+
+// myersDiff finds a shortest edit script turning a into b with Myers'
+// algorithm. It gives up when that takes more than maxEdits edits.
+func myersDiff(a, b []string, maxEdits int) ([]diffLine, bool) {
+	// trace[d][k+d] is the furthest x reached on diagonal k = x-y with d
+	// edits, only diagonals of the same parity as d are filled
+	var trace [][]int
+	for d := 0; d <= maxEdits; d++ {
+		v := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			x := 0
+			if d > 0 {
+				prev := trace[d-1]
+				if k == -d || k != d && prev[k-1+d-1] < prev[k+1+d-1] {
+					// down from diagonal k+1, an insertion
+					x = prev[k+1+d-1]
+				} else {
+					// right from diagonal k-1, a deletion
+					x = prev[k-1+d-1] + 1
+				}
+			}
+			y := x - k
+			for x < len(a) && y < len(b) && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+d] = x
+			if x >= len(a) && y >= len(b) {
+				return myersPath(a, b, append(trace, v)), true
+			}
+		}
+		trace = append(trace, v)
+	}
+	return nil, false
+}
+
+// myersPath walks trace of myersDiff back from the end of a and b.
+func myersPath(a, b []string, trace [][]int) []diffLine {
+	var res []diffLine
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		k := x - y
+		prevK := k - 1
+		if k == -d || k != d && prev[k-1+d-1] < prev[k+1+d-1] {
+			prevK = k + 1
+		}
+		prevX := prev[prevK+d-1]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			res = append(res, diffLine{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			res = append(res, diffLine{'+', b[y-1]})
+			y--
+		} else {
+			res = append(res, diffLine{'-', a[x-1]})
+			x--
+		}
+	}
+	for ; x > 0; x-- {
+		res = append(res, diffLine{' ', a[x-1]})
+	}
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res
+}
+
+// unifiedDiff writes the difference between a and b in unified format and
+// reports whether there was any.
+func unifiedDiff(out io.Writer, nameA, nameB string, a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return false
+	}
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	fmt.Fprintf(out, "--- %v\n+++ %v\n", nameA, nameB)
+	lineA, lineB := 1, 1
+	for start := 0; start < len(lines); {
+		// skip to the next change
+		for start < len(lines) && lines[start].kind == ' ' {
+			start++
+			lineA++
+			lineB++
+		}
+		if start == len(lines) {
+			break
+		}
+
+		// a hunk ends when more than 2*diffContext unchanged lines follow a change
+		end := start
+		for unchanged := 0; end < len(lines) && unchanged <= 2*diffContext; end++ {
+			if lines[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		for end > start && lines[end-1].kind == ' ' {
+			end--
+		}
+
+		before := min(diffContext, start)
+		after := min(diffContext, len(lines)-end)
+		hunk := lines[start-before : end+after]
+		countA, countB := 0, 0
+		for _, line := range hunk {
+			if line.kind != '+' {
+				countA++
+			}
+			if line.kind != '-' {
+				countB++
+			}
+		}
+		startA, startB := lineA-before, lineB-before
+		// an empty range is addressed by the line before it
+		if countA == 0 {
+			startA--
+		}
+		if countB == 0 {
+			startB--
+		}
+		fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", startA, countA, startB, countB)
+		for _, line := range hunk {
+			text := line.text
+			if !strings.HasSuffix(text, "\n") {
+				text += "\n\\ No newline at end of file\n"
+			}
+			fmt.Fprintf(out, "%c%v", line.kind, text)
+		}
+
+		for _, line := range lines[start:end] {
+			if line.kind != '+' {
+				lineA++
+			}
+			if line.kind != '-' {
+				lineB++
+			}
+		}
+		start = end
+	}
+	return true
+}
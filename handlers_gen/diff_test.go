@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func lines(from, to int, change map[int]string) string {
+	var res strings.Builder
+	for i := from; i <= to; i++ {
+		if text, ok := change[i]; ok {
+			res.WriteString(text)
+		} else {
+			fmt.Fprintf(&res, "%d\n", i)
+		}
+	}
+	return res.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := []struct {
+		Name string
+		A, B string
+		Diff string
+	}{
+		{
+			Name: "identical",
+			A:    "a\nb\n",
+			B:    "a\nb\n",
+			Diff: "",
+		},
+		{
+			Name: "change",
+			A:    lines(1, 10, nil),
+			B:    lines(1, 10, map[int]string{5: "five\n"}),
+			Diff: "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			Name: "two hunks",
+			A:    lines(1, 20, nil),
+			B:    lines(1, 20, map[int]string{2: "two\n", 18: "eighteen\n"}),
+			Diff: "@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -15,6 +15,6 @@\n 15\n 16\n 17\n-18\n+eighteen\n 19\n 20\n",
+		},
+		{
+			Name: "close changes share a hunk",
+			A:    lines(1, 12, nil),
+			B:    lines(1, 12, map[int]string{3: "", 9: "nine\n"}),
+			Diff: "@@ -1,12 +1,11 @@\n 1\n 2\n-3\n 4\n 5\n 6\n 7\n 8\n-9\n+nine\n 10\n 11\n 12\n",
+		},
+		{
+			Name: "no newline at end",
+			A:    "a\nb\n",
+			B:    "a\nb",
+			Diff: "@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			Name: "empty old",
+			A:    "",
+			B:    "a\nb\n",
+			Diff: "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			Name: "empty new",
+			A:    "a\nb\n",
+			B:    "",
+			Diff: "@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+	}
+
+	for _, item := range cases {
+		var out bytes.Buffer
+		changed := unifiedDiff(&out, "old", "new", []byte(item.A), []byte(item.B))
+		expected := ""
+		if item.Diff != "" {
+			expected = "--- old\n+++ new\n" + item.Diff
+		}
+		if changed != (item.Diff != "") || out.String() != expected {
+			t.Errorf("[%v] diff not match\nGot:\n%v\nExpected:\n%v", item.Name, out.String(), expected)
+		}
+	}
+}
+
+// TestDiffLines checks on random input that the diff turns a into b with the
+// least number of changes.
+func TestDiffLines(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		res := make([]string, random.Intn(30))
+		for i := range res {
+			res[i] = string(rune('a' + random.Intn(4)))
+		}
+		return res
+	}
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		var gotA, gotB []string
+		changes := 0
+		for _, line := range diffLines(a, b) {
+			if line.kind != '+' {
+				gotA = append(gotA, line.text)
+			}
+			if line.kind != '-' {
+				gotB = append(gotB, line.text)
+			}
+			if line.kind != ' ' {
+				changes++
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("[%d] diff of %q and %q does not restore them", i, a, b)
+		}
+		if minimal := len(a) + len(b) - 2*lcsLength(a, b); changes != minimal {
+			t.Fatalf("[%d] diff of %q and %q has %d changes, expected %d", i, a, b, changes, minimal)
+		}
+	}
+}
+
+func lcsLength(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return lcs[0][0]
+}
+
+func TestDiffLinesCutoff(t *testing.T) {
+	a := make([]string, 3*maxDiffEdits)
+	b := make([]string, 3*maxDiffEdits)
+	for i := range a {
+		a[i] = fmt.Sprintf("a%d\n", i)
+		b[i] = fmt.Sprintf("b%d\n", i)
+	}
+	a[0], b[0] = "same\n", "same\n"
+	res := diffLines(a, b)
+	if len(res) != len(a)+len(b)-1 || res[0] != (diffLine{' ', "same\n"}) || res[1].kind != '-' || res[len(res)-1].kind != '+' {
+		t.Errorf("unexpected diff of unrelated files, %d lines", len(res))
+	}
+}
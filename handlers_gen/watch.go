@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fileState is what watch remembers about a source file between polls.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	// annotated is set for files with one of annotationMarkers
+	annotated bool
+}
+
+// annotationMarkers are what makes a file matter to the generator:
+// apigen:api and apigen:service comments and apivalidator tags.
+var annotationMarkers = [][]byte{[]byte("apigen:api"), []byte("apigen:service"), []byte("apivalidator")}
+
+// watch calls generate at once and then each time an annotated file of the
+// input package is changed, added or removed. It polls modification times
+// every interval so it works the same everywhere, and never returns.
+func watch(input, output string, interval time.Duration, generate func()) {
+	files := scan(input, output, nil)
+	generate()
+	for {
+		time.Sleep(interval)
+		next := scan(input, output, files)
+		if changed := changedFiles(files, next); len(changed) > 0 {
+			fmt.Println(time.Now().Format(time.TimeOnly), strings.Join(changed, ", "), "changed")
+			generate()
+		}
+		files = next
+	}
+}
+
+// scan returns the state of the package sources. Files are read only when
+// they differ from prev, generated files including the output are skipped.
+func scan(input, output string, prev map[string]fileState) map[string]fileState {
+	paths := []string{input}
+	if info, err := os.Stat(input); err == nil && info.IsDir() {
+		paths, _ = filepath.Glob(filepath.Join(input, "*.go"))
+	}
+	outputPath, _ := filepath.Abs(output)
+
+	res := make(map[string]fileState)
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		if absPath, _ := filepath.Abs(path); absPath == outputPath {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		state := fileState{modTime: info.ModTime(), size: info.Size()}
+		if old, ok := prev[path]; ok && old.modTime.Equal(state.modTime) && old.size == state.size {
+			res[path] = old
+			continue
+		}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, src, parser.PackageClauseOnly|parser.ParseComments)
+		if err == nil && ast.IsGenerated(file) {
+			continue
+		}
+		for _, marker := range annotationMarkers {
+			state.annotated = state.annotated || bytes.Contains(src, marker)
+		}
+		res[path] = state
+	}
+	return res
+}
+
+// changedFiles lists files which have to trigger generation: annotated ones
+// which are new, removed or modified, and files which lost their annotations.
+func changedFiles(prev, next map[string]fileState) []string {
+	var res []string
+	for path, state := range next {
+		old, ok := prev[path]
+		switch {
+		case !ok:
+			if state.annotated {
+				res = append(res, path)
+			}
+		case old.modTime.Equal(state.modTime) && old.size == state.size:
+		case old.annotated || state.annotated:
+			res = append(res, path)
+		}
+	}
+	for path, state := range prev {
+		if _, ok := next[path]; !ok && state.annotated {
+			res = append(res, path)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
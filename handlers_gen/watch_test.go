@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScanChanges(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string, modTime time.Time) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	start := time.Now().Add(-time.Hour)
+	service := write("service.go", "package api\n\n// apigen:service {\"prefix\": \"/v1\"}\ntype Api struct{}\n", start)
+	plain := write("plain.go", "package api\n\nconst X = 1\n", start)
+	write("api_handlers.go", "// Code generated by codegen; DO NOT EDIT.\n\npackage api\n", start)
+
+	files := scan(dir, filepath.Join(dir, "api_handlers.go"), nil)
+	if len(files) != 2 || !files[service].annotated || files[plain].annotated {
+		t.Fatalf("unexpected scan %+v", files)
+	}
+
+	write("service.go", "package api\n\n// apigen:service {\"prefix\": \"/v2\"}\ntype Api struct{}\n", start.Add(time.Minute))
+	write("plain.go", "package api\n\nconst X = 2\n", start.Add(time.Minute))
+	next := scan(dir, filepath.Join(dir, "api_handlers.go"), files)
+	if changed := changedFiles(files, next); !reflect.DeepEqual(changed, []string{service}) {
+		t.Errorf("unexpected changes %v", changed)
+	}
+	if changed := changedFiles(next, scan(dir, filepath.Join(dir, "api_handlers.go"), next)); len(changed) != 0 {
+		t.Errorf("unexpected changes without edits %v", changed)
+	}
+
+	if err := os.Remove(service); err != nil {
+		t.Fatal(err)
+	}
+	if changed := changedFiles(next, scan(dir, filepath.Join(dir, "api_handlers.go"), next)); !reflect.DeepEqual(changed, []string{service}) {
+		t.Errorf("removal is not noticed: %v", changed)
+	}
+}